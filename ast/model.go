@@ -3,6 +3,8 @@ package ast
 import (
 	"fmt"
 	"inky/token"
+	"reflect"
+	"strings"
 )
 
 // Node is the parent interface for all AST nodes
@@ -21,6 +23,15 @@ type Stmt interface {
 }
 
 // Integer represents an integer expression.
+// str formats a child node as "nil" when it is missing, as optional
+// children are and as nodes built by a script may be.
+func str(n Node) string {
+	if v := reflect.ValueOf(n); !v.IsValid() || v.Kind() == reflect.Pointer && v.IsNil() {
+		return "nil"
+	}
+	return n.String()
+}
+
 type Integer struct {
 	Value int
 	Line  int
@@ -60,6 +71,15 @@ func (s String) String() string {
 	return fmt.Sprintf("String[%s]", s.Value)
 }
 
+// Null represents the null literal.
+type Null struct {
+	Line int
+}
+
+func (n Null) String() string {
+	return "Null"
+}
+
 // BinOp represents a binary operation like x + y.
 type BinOp struct {
 	Op    token.Token
@@ -69,7 +89,7 @@ type BinOp struct {
 }
 
 func (b BinOp) String() string {
-	return fmt.Sprintf("BinOp(%q, %s, %s)", b.Op.Lexeme, b.Left.String(), b.Right.String())
+	return fmt.Sprintf("BinOp(%q, %s, %s)", b.Op.Lexeme, str(b.Left), str(b.Right))
 }
 
 // UnOp represents a unary operation like -x.
@@ -80,7 +100,7 @@ type UnOp struct {
 }
 
 func (u UnOp) String() string {
-	return fmt.Sprintf("UnOp(%q, %s)", u.Op.Lexeme, u.Operand.String())
+	return fmt.Sprintf("UnOp(%q, %s)", u.Op.Lexeme, str(u.Operand))
 }
 
 // Grouping represents a grouped expression like (x + y).
@@ -90,7 +110,7 @@ type Grouping struct {
 }
 
 func (g Grouping) String() string {
-	return fmt.Sprintf("Grouping(%s)", g.Value.String())
+	return fmt.Sprintf("Grouping(%s)", str(g.Value))
 }
 
 // LogicalOp represents a logical operation like x and y.
@@ -102,7 +122,7 @@ type LogicalOp struct {
 }
 
 func (l LogicalOp) String() string {
-	return fmt.Sprintf("Logical(%q, %s, %s)", l.Op.Lexeme, l.Left.String(), l.Right.String())
+	return fmt.Sprintf("Logical(%q, %s, %s)", l.Op.Lexeme, str(l.Left), str(l.Right))
 }
 
 // Stmts represents a list of statements.
@@ -122,7 +142,7 @@ type PrintStmt struct {
 }
 
 func (p PrintStmt) String() string {
-	return fmt.Sprintf("PrintStmt(%s, end=%q)", p.Value.String(), p.End)
+	return fmt.Sprintf("PrintStmt(%s, end=%q)", str(p.Value), p.End)
 }
 
 type IfStmt struct {
@@ -133,13 +153,7 @@ type IfStmt struct {
 }
 
 func (i IfStmt) String() string {
-	var elseStr string
-	if i.ElseStmts != nil {
-		elseStr = i.ElseStmts.String()
-	} else {
-		elseStr = "nil"
-	}
-	return fmt.Sprintf("IfStmt(%s, then:%s, else:%s)", i.Condition.String(), i.ThenStmts.String(), elseStr)
+	return fmt.Sprintf("IfStmt(%s, then:%s, else:%s)", str(i.Condition), str(i.ThenStmts), str(i.ElseStmts))
 }
 
 type Identifier struct {
@@ -154,9 +168,221 @@ func (i Identifier) String() string {
 type AssignStmt struct {
 	Left  Expr
 	Right Expr
+	Type  string // optional annotation, empty when the binding is dynamically typed
 	Line  int
 }
 
 func (a AssignStmt) String() string {
-	return fmt.Sprintf("AssignStmt(%s, %s)", a.Left.String(), a.Right.String())
+	if a.Type != "" {
+		return fmt.Sprintf("AssignStmt(%s: %s, %s)", str(a.Left), a.Type, str(a.Right))
+	}
+	return fmt.Sprintf("AssignStmt(%s, %s)", str(a.Left), str(a.Right))
+}
+
+// ConstStmt represents an immutable binding like const x := 1.
+type ConstStmt struct {
+	Name  Identifier
+	Right Expr
+	Type  string // optional annotation
+	Line  int
+}
+
+func (c ConstStmt) String() string {
+	if c.Type != "" {
+		return fmt.Sprintf("ConstStmt(%s: %s, %s)", c.Name.String(), c.Type, str(c.Right))
+	}
+	return fmt.Sprintf("ConstStmt(%s, %s)", c.Name.String(), str(c.Right))
+}
+
+// RecordDecl represents a record declaration like record Point(x, y).
+type RecordDecl struct {
+	Name   string
+	Fields []string
+	Line   int
+}
+
+func (r RecordDecl) String() string {
+	return fmt.Sprintf("RecordDecl(%q, %v)", r.Name, r.Fields)
+}
+
+// Call represents a call expression like Point(1, 2).
+type Call struct {
+	Callee Expr
+	Args   []Expr
+	Line   int
+}
+
+func (c Call) String() string {
+	return fmt.Sprintf("Call(%s, %v)", str(c.Callee), c.Args)
+}
+
+// FieldAccess represents reading or writing a field like p.x.
+type FieldAccess struct {
+	Object Expr
+	Field  string
+	Line   int
+}
+
+func (f FieldAccess) String() string {
+	return fmt.Sprintf("FieldAccess(%s, %q)", str(f.Object), f.Field)
+}
+
+// Index represents indexing like s[i].
+type Index struct {
+	Object Expr
+	Index  Expr
+	Line   int
+}
+
+func (i Index) String() string {
+	return fmt.Sprintf("Index(%s, %s)", str(i.Object), str(i.Index))
+}
+
+// Slice represents slicing like s[start:stop:step]. Omitted bounds are nil.
+type Slice struct {
+	Object Expr
+	Start  Expr
+	Stop   Expr
+	Step   Expr
+	Line   int
+}
+
+func (s Slice) String() string {
+	return fmt.Sprintf("Slice(%s, %s, %s, %s)", str(s.Object), str(s.Start), str(s.Stop), str(s.Step))
+}
+
+// Range represents a range expression like 1..10 or 1..<10.
+type Range struct {
+	Start     Expr
+	End       Expr
+	Inclusive bool
+	Line      int
+}
+
+func (r Range) String() string {
+	return fmt.Sprintf("Range(%s, %s, inclusive=%t)", str(r.Start), str(r.End), r.Inclusive)
+}
+
+// ListLiteral represents a list like [1, 2, 3].
+type ListLiteral struct {
+	Elements []Expr
+	Line     int
+}
+
+func (l ListLiteral) String() string {
+	return fmt.Sprintf("ListLiteral(%v)", l.Elements)
+}
+
+// MapLiteral represents a map like {"a": 1, "b": 2}.
+type MapLiteral struct {
+	Keys   []Expr
+	Values []Expr
+	Line   int
+}
+
+func (m MapLiteral) String() string {
+	pairs := make([]string, len(m.Keys))
+	for i := range m.Keys {
+		pairs[i] = fmt.Sprintf("%s: %s", str(m.Keys[i]), str(m.Values[i]))
+	}
+	return fmt.Sprintf("MapLiteral(%v)", pairs)
+}
+
+// CompClause is the 'for vars in iterable if cond' part of a comprehension.
+// Cond is nil when there is no filter.
+type CompClause struct {
+	Vars     []string
+	Iterable Expr
+	Cond     Expr
+}
+
+func (c CompClause) String() string {
+	if c.Cond != nil {
+		return fmt.Sprintf("for %v in %s if %s", c.Vars, str(c.Iterable), str(c.Cond))
+	}
+	return fmt.Sprintf("for %v in %s", c.Vars, str(c.Iterable))
+}
+
+// ListComp represents a list comprehension like [x * x for x in xs if x > 0].
+type ListComp struct {
+	Element Expr
+	Clause  CompClause
+	Line    int
+}
+
+func (l ListComp) String() string {
+	return fmt.Sprintf("ListComp(%s, %s)", str(l.Element), l.Clause.String())
+}
+
+// MapComp represents a map comprehension like {k: v for k, v in m}.
+type MapComp struct {
+	Key    Expr
+	Value  Expr
+	Clause CompClause
+	Line   int
+}
+
+func (m MapComp) String() string {
+	return fmt.Sprintf("MapComp(%s: %s, %s)", str(m.Key), str(m.Value), m.Clause.String())
+}
+
+// EnumVariant is one alternative of an enum, with the names of its payload fields.
+type EnumVariant struct {
+	Name   string
+	Fields []string
+}
+
+func (v EnumVariant) String() string {
+	if len(v.Fields) == 0 {
+		return v.Name
+	}
+	return fmt.Sprintf("%s(%s)", v.Name, strings.Join(v.Fields, ", "))
+}
+
+// EnumDecl represents a tagged union like enum Shape = Circle(r) | Rect(w, h).
+type EnumDecl struct {
+	Name     string
+	Variants []EnumVariant
+	Line     int
+}
+
+func (e EnumDecl) String() string {
+	return fmt.Sprintf("EnumDecl(%q, %v)", e.Name, e.Variants)
+}
+
+// MatchCase is one 'case Variant(bindings) then stmts' arm of a match.
+type MatchCase struct {
+	Variant  string
+	Bindings []string
+	Stmts    *Stmts
+	Line     int
+}
+
+func (m MatchCase) String() string {
+	return fmt.Sprintf("case %s%v: %s", m.Variant, m.Bindings, str(m.Stmts))
+}
+
+// MatchStmt dispatches on the variant of an enum value.
+type MatchStmt struct {
+	Subject   Expr
+	Cases     []MatchCase
+	ElseStmts *Stmts
+	Line      int
+}
+
+func (m MatchStmt) String() string {
+	return fmt.Sprintf("MatchStmt(%s, %v, else:%s)", str(m.Subject), m.Cases, str(m.ElseStmts))
+}
+
+// MacroDecl represents a macro definition like macro unless(cond, body) ... end.
+// Macros are expanded away before the program is interpreted.
+type MacroDecl struct {
+	Name   string
+	Params []string
+	Body   *Stmts
+	Line   int
+}
+
+func (m MacroDecl) String() string {
+	return fmt.Sprintf("MacroDecl(%q, %v, %s)", m.Name, m.Params, str(m.Body))
 }
@@ -0,0 +1,332 @@
+package checker
+
+import (
+	"fmt"
+	"inky/ast"
+	"inky/token"
+)
+
+// Static types understood by the checker. TYPE_ANY marks a value whose type
+// is only known at run time, such as an unannotated variable.
+const (
+	TYPE_INT    = "int"
+	TYPE_FLOAT  = "float"
+	TYPE_STRING = "string"
+	TYPE_BOOL   = "bool"
+	TYPE_ANY    = "any"
+)
+
+var annotations = map[string]bool{
+	TYPE_INT:    true,
+	TYPE_FLOAT:  true,
+	TYPE_STRING: true,
+	TYPE_BOOL:   true,
+}
+
+// TypeError is a type mismatch found before the program runs.
+type TypeError struct {
+	Msg  string
+	Line int
+}
+
+func (e TypeError) Error() string {
+	return fmt.Sprintf("[Line %d]: %s", e.Line, e.Msg)
+}
+
+// Checker walks the AST inferring expression types. Only annotated bindings
+// keep a static type; everything else stays dynamically typed as TYPE_ANY.
+type Checker struct {
+	vars   map[string]string // variable name -> annotated type
+	errors []TypeError
+}
+
+func NewChecker() *Checker {
+	return &Checker{vars: map[string]string{}}
+}
+
+// Check reports every type error in node without evaluating it.
+func (c *Checker) Check(node ast.Node) []TypeError {
+	c.check(node)
+	return c.errors
+}
+
+func (c *Checker) errorf(line int, format string, args ...any) {
+	c.errors = append(c.errors, TypeError{Msg: fmt.Sprintf(format, args...), Line: line})
+}
+
+func (c *Checker) check(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.Stmts:
+		for _, stmt := range node.Stmts {
+			c.check(stmt)
+		}
+	case *ast.PrintStmt:
+		c.infer(node.Value)
+	case *ast.IfStmt:
+		// Any value may be used as a condition through truthiness
+		c.infer(node.Condition)
+		c.check(node.ThenStmts)
+		if node.ElseStmts != nil {
+			c.check(node.ElseStmts)
+		}
+	case *ast.AssignStmt:
+		typ := c.infer(node.Right)
+		switch left := node.Left.(type) {
+		case *ast.Identifier:
+			c.bind(left.Name, node.Type, typ, left.Line)
+		case *ast.FieldAccess:
+			c.infer(left.Object)
+		case *ast.Index:
+			c.infer(left.Object)
+			c.infer(left.Index)
+		}
+	case *ast.ConstStmt:
+		c.bind(node.Name.Name, node.Type, c.infer(node.Right), node.Line)
+	case *ast.MatchStmt:
+		c.infer(node.Subject)
+		for _, arm := range node.Cases {
+			// Payload bindings are dynamically typed assignments
+			for _, name := range arm.Bindings {
+				c.bind(name, "", TYPE_ANY, arm.Line)
+			}
+			c.check(arm.Stmts)
+		}
+		if node.ElseStmts != nil {
+			c.check(node.ElseStmts)
+		}
+	default:
+		c.infer(node)
+	}
+}
+
+// bind records an assignment of a value of type typ to name, checking it
+// against the annotation given here or on an earlier assignment.
+func (c *Checker) bind(name string, annotation string, typ string, line int) {
+	if annotation != "" {
+		if !annotations[annotation] {
+			c.errorf(line, "unknown type %q", annotation)
+			return
+		}
+		if declared, ok := c.vars[name]; ok && declared != annotation {
+			c.errorf(line, "cannot redeclare %q as %s, it was declared as %s", name, annotation, declared)
+			return
+		}
+		c.vars[name] = annotation
+	}
+	if declared, ok := c.vars[name]; ok && !assignable(typ, declared) {
+		c.errorf(line, "cannot assign %s to %q of type %s", typ, name, declared)
+	}
+}
+
+// assignable reports whether a value of type from can be stored in a binding of type to.
+func assignable(from string, to string) bool {
+	return from == TYPE_ANY || from == to || (from == TYPE_INT && to == TYPE_FLOAT)
+}
+
+func isNumeric(typ string) bool {
+	return typ == TYPE_INT || typ == TYPE_FLOAT
+}
+
+// infer returns the static type of an expression, reporting operator misuse
+// when both operand types are known.
+func (c *Checker) infer(node ast.Node) string {
+	switch node := node.(type) {
+	case *ast.Integer:
+		return TYPE_INT
+	case *ast.Float:
+		return TYPE_FLOAT
+	case *ast.String:
+		return TYPE_STRING
+	case *ast.Bool:
+		return TYPE_BOOL
+	case *ast.Grouping:
+		return c.infer(node.Value)
+	case *ast.Identifier:
+		if typ, ok := c.vars[node.Name]; ok {
+			return typ
+		}
+		return TYPE_ANY
+	case *ast.BinOp:
+		return c.inferBinOp(node)
+	case *ast.UnOp:
+		return c.inferUnOp(node)
+	case *ast.Call:
+		c.infer(node.Callee)
+		for _, arg := range node.Args {
+			c.infer(arg)
+		}
+		return TYPE_ANY
+	case *ast.FieldAccess:
+		c.infer(node.Object)
+		return TYPE_ANY
+	case *ast.Index:
+		// Maps accept any key, so only strings and lists need an int
+		object := c.infer(node.Object)
+		if object == TYPE_STRING || isList(node.Object) {
+			c.checkInt(node.Index, node.Line, "index")
+		} else {
+			c.infer(node.Index)
+		}
+		if object == TYPE_STRING {
+			return TYPE_STRING
+		}
+		return TYPE_ANY
+	case *ast.Slice:
+		for _, bound := range []ast.Expr{node.Start, node.Stop, node.Step} {
+			if bound != nil {
+				c.checkInt(bound, node.Line, "index")
+			}
+		}
+		if c.infer(node.Object) == TYPE_STRING {
+			return TYPE_STRING
+		}
+		return TYPE_ANY
+	case *ast.Range:
+		c.checkInt(node.Start, node.Line, "range bound")
+		c.checkInt(node.End, node.Line, "range bound")
+		return TYPE_ANY
+	case *ast.ListLiteral:
+		for _, elem := range node.Elements {
+			c.infer(elem)
+		}
+		return TYPE_ANY
+	case *ast.MapLiteral:
+		for idx := range node.Keys {
+			c.infer(node.Keys[idx])
+			c.infer(node.Values[idx])
+		}
+		return TYPE_ANY
+	case *ast.ListComp:
+		c.inferComp(node.Clause, node.Element)
+		return TYPE_ANY
+	case *ast.MapComp:
+		c.inferComp(node.Clause, node.Key, node.Value)
+		return TYPE_ANY
+	case *ast.LogicalOp:
+		// 'and' and 'or' return one of their operands
+		left := c.infer(node.Left)
+		right := c.infer(node.Right)
+		if left == right {
+			return left
+		}
+		return TYPE_ANY
+	default:
+		return TYPE_ANY
+	}
+}
+
+// inferComp checks a comprehension. Its loop variables are dynamically typed
+// and shadow any annotated variable of the same name inside the comprehension.
+func (c *Checker) inferComp(clause ast.CompClause, exprs ...ast.Expr) {
+	c.infer(clause.Iterable)
+	shadowed := map[string]string{}
+	for _, name := range clause.Vars {
+		if typ, ok := c.vars[name]; ok {
+			shadowed[name] = typ
+			delete(c.vars, name)
+		}
+	}
+	if clause.Cond != nil {
+		c.infer(clause.Cond)
+	}
+	for _, expr := range exprs {
+		c.infer(expr)
+	}
+	for name, typ := range shadowed {
+		c.vars[name] = typ
+	}
+}
+
+// checkInt reports an index, slice or range bound that is known not to be an int.
+// isNonNegative reports whether node is known to evaluate to a number of
+// at least zero.
+func isNonNegative(node ast.Expr) bool {
+	switch node := node.(type) {
+	case *ast.Integer:
+		return node.Value >= 0
+	case *ast.Grouping:
+		return isNonNegative(node.Value)
+	}
+	return false
+}
+
+// isList reports whether node is known to evaluate to a list.
+func isList(node ast.Expr) bool {
+	switch node.(type) {
+	case *ast.ListLiteral, *ast.ListComp:
+		return true
+	}
+	return false
+}
+
+func (c *Checker) checkInt(node ast.Expr, line int, what string) {
+	if typ := c.infer(node); typ != TYPE_ANY && typ != TYPE_INT {
+		c.errorf(line, "%s must be int, got %s", what, typ)
+	}
+}
+
+func (c *Checker) inferBinOp(node *ast.BinOp) string {
+	left := c.infer(node.Left)
+	right := c.infer(node.Right)
+	if node.Op.Type == token.TOK_CONCAT {
+		return TYPE_STRING
+	}
+	if left == TYPE_ANY || right == TYPE_ANY {
+		return TYPE_ANY
+	}
+
+	switch node.Op.Type {
+	case token.TOK_IN:
+		if right != TYPE_STRING || left == TYPE_STRING {
+			return TYPE_BOOL
+		}
+	case token.TOK_PLUS:
+		if left == TYPE_STRING && right == TYPE_STRING {
+			return TYPE_STRING
+		}
+		fallthrough
+	case token.TOK_MINUS, token.TOK_STAR, token.TOK_MOD, token.TOK_CARET:
+		if isNumeric(left) && isNumeric(right) {
+			// A negative exponent makes a fraction, e.g. 2 ^ -1
+			if node.Op.Type == token.TOK_CARET && !isNonNegative(node.Right) {
+				return TYPE_FLOAT
+			}
+			if left == TYPE_INT && right == TYPE_INT {
+				return TYPE_INT
+			}
+			return TYPE_FLOAT
+		}
+	case token.TOK_SLASH:
+		if isNumeric(left) && isNumeric(right) {
+			return TYPE_FLOAT
+		}
+	case token.TOK_GT, token.TOK_LT, token.TOK_GE, token.TOK_LE:
+		if (isNumeric(left) && isNumeric(right)) || (left == TYPE_STRING && right == TYPE_STRING) {
+			return TYPE_BOOL
+		}
+	case token.TOK_EQEQ, token.TOK_NE:
+		if (isNumeric(left) && isNumeric(right)) || left == right {
+			return TYPE_BOOL
+		}
+	}
+	c.errorf(node.Line, "unsupported operator %v between %v and %v", node.Op.Lexeme, left, right)
+	return TYPE_ANY
+}
+
+func (c *Checker) inferUnOp(node *ast.UnOp) string {
+	operand := c.infer(node.Operand)
+	if operand == TYPE_ANY {
+		return TYPE_ANY
+	}
+
+	switch node.Op.Type {
+	case token.TOK_MINUS, token.TOK_PLUS:
+		if isNumeric(operand) {
+			return operand
+		}
+	case token.TOK_NOT:
+		return TYPE_BOOL
+	}
+	c.errorf(node.Line, "unsupported unary operator %v on type %v", node.Op.Lexeme, operand)
+	return TYPE_ANY
+}
@@ -1,10 +1,19 @@
 package main
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
+	"inky/checker"
 	"inky/interpreter"
 	"inky/lexer"
+	"inky/macro"
 	"inky/parser"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 type TestCase struct {
@@ -54,9 +63,14 @@ func TestE2E(t *testing.T) {
 
 		// Unary operators
 		{
-			name:     "Negation",
+			name:     "Logical not of a number",
 			source:   "~3",
-			expected: float64(-3),
+			expected: false,
+		},
+		{
+			name:     "Logical not of zero",
+			source:   "~0",
+			expected: true,
 		},
 		{
 			name:     "Unary minus",
@@ -70,7 +84,7 @@ func TestE2E(t *testing.T) {
 		},
 		{
 			name:     "Double negation",
-			source:   "~-5",
+			source:   "-(-5)",
 			expected: float64(5),
 		},
 
@@ -240,6 +254,549 @@ func TestE2E(t *testing.T) {
 			source:   "2^3 + 1 > 8 and ~false",
 			expected: true,
 		},
+
+		// Truthiness
+		{
+			name:     "Or returns the first truthy operand",
+			source:   "name := null\nname or \"anonymous\"",
+			expected: "anonymous",
+		},
+		{
+			name:     "Or keeps a truthy left operand",
+			source:   "\"inky\" or \"anonymous\"",
+			expected: "inky",
+		},
+		{
+			name:     "And returns the falsy operand",
+			source:   "0 and 5",
+			expected: float64(0),
+		},
+		{
+			name:     "And returns the right operand",
+			source:   "\"a\" and 5",
+			expected: float64(5),
+		},
+		{
+			name:     "Not on empty string",
+			source:   "~\"\"",
+			expected: true,
+		},
+		{
+			name:     "Not on null",
+			source:   "~null",
+			expected: true,
+		},
+		{
+			name:     "Null equality",
+			source:   "null == null and null ~= 0",
+			expected: true,
+		},
+		{
+			name:     "If on a non-bool condition",
+			source:   "x := \"\"\nif x then x := 1 else x := 2 end\nx",
+			expected: float64(2),
+		},
+		{
+			name:     "If on zero",
+			source:   "x := 0\nif x then x := 1 end\nx",
+			expected: float64(0),
+		},
+
+		// Indexing and slicing
+		{
+			name:     "String index",
+			source:   "\"inky\"[1]",
+			expected: "n",
+		},
+		{
+			name:     "Negative string index",
+			source:   "\"inky\"[-1]",
+			expected: "y",
+		},
+		{
+			name:     "String slice",
+			source:   "s := \"hello world\"\ns[0:5] + s[5:]",
+			expected: "hello world",
+		},
+		{
+			name:     "String slice by rune",
+			source:   "\"héllo\"[1:3]",
+			expected: "él",
+		},
+		{
+			name:     "String slice with step",
+			source:   "\"abcdef\"[::2]",
+			expected: "ace",
+		},
+		{
+			name:     "String slice reversed",
+			source:   "\"abc\"[::-1]",
+			expected: "cba",
+		},
+		{
+			name:     "String slice clamps out of range bounds",
+			source:   "\"abc\"[-10:10]",
+			expected: "abc",
+		},
+
+		// Ranges and concatenation
+		{
+			name:     "Concatenation converts operands",
+			source:   "\"n=\" ++ 3 ++ \", ok=\" ++ true",
+			expected: "n=3, ok=true",
+		},
+		{
+			name:     "Plus concatenates strings",
+			source:   "\"ab\" + \"cd\"",
+			expected: "abcd",
+		},
+		{
+			name:     "Inclusive range membership",
+			source:   "10 in 1..10",
+			expected: true,
+		},
+		{
+			name:     "Exclusive range membership",
+			source:   "10 in 1..<10",
+			expected: false,
+		},
+		{
+			name:     "Range membership rejects fractions",
+			source:   "2.5 in 1..10",
+			expected: false,
+		},
+		{
+			name:     "Range with expressions",
+			source:   "n := 3\nn * 2 in n..n + n",
+			expected: true,
+		},
+		{
+			name:     "Substring membership",
+			source:   "\"nk\" in \"inky\"",
+			expected: true,
+		},
+		{
+			name:     "Slice with a range",
+			source:   "\"hello\"[1..3]",
+			expected: "ell",
+		},
+		{
+			name:     "Slice with a range to the end",
+			source:   "\"hello\"[1..-1] ++ \"hello\"[0..<1]",
+			expected: "elloh",
+		},
+
+		// Lists, maps and comprehensions
+		{
+			name:     "List index",
+			source:   "xs := [1, \"two\", 3]\nxs[1] ++ xs[-1]",
+			expected: "two3",
+		},
+		{
+			name:     "List index assignment",
+			source:   "xs := [1, 2, 3]\nxs[0] := 10\nxs[0] + xs[2]",
+			expected: float64(13),
+		},
+		{
+			name:     "List slice and concatenation",
+			source:   "xs := [1, 2, 3, 4]\nxs[1:3] + [5] == [2, 3, 5]",
+			expected: true,
+		},
+		{
+			name:     "Map lookup and assignment",
+			source:   "m := {\"a\": 1}\nm[\"b\"] := 2\nm[\"a\"] + m[\"b\"]",
+			expected: float64(3),
+		},
+		{
+			name:     "Missing map key is null",
+			source:   "m := {}\nm[\"a\"] or \"default\"",
+			expected: "default",
+		},
+		{
+			name:     "List membership",
+			source:   "[1, 2] in [[1, 2], [3]]",
+			expected: true,
+		},
+		{
+			name:     "Empty list is falsy",
+			source:   "[] or \"empty\"",
+			expected: "empty",
+		},
+		{
+			name:     "List comprehension with filter",
+			source:   "xs := [3, -1, 4]\n[x * x for x in xs if x > 0] == [9, 16]",
+			expected: true,
+		},
+		{
+			name:     "List comprehension over a range",
+			source:   "[n for n in 1..10 if n % 4 == 0] == [4, 8]",
+			expected: true,
+		},
+		{
+			name:     "Map comprehension",
+			source:   "m := {\"a\": 1, \"b\": 2}\n{k: v * 10 for k, v in m} == {\"b\": 20, \"a\": 10}",
+			expected: true,
+		},
+		{
+			name:     "Map comprehension from a list",
+			source:   "m := {s: i for i, s in [\"x\", \"y\"]}\nm[\"y\"]",
+			expected: float64(1),
+		},
+		{
+			name:     "Comprehension variables do not leak",
+			source:   "x := \"outer\"\nys := [x for x in [1, 2]]\nx",
+			expected: "outer",
+		},
+
+		// Pipelines
+		{
+			name:     "Pipeline passes the left value as the first argument",
+			source:   "record Pair(a, b)\np := 1 |> Pair(2)\np.a - p.b",
+			expected: float64(-1),
+		},
+		{
+			name:     "Pipeline into a bare callee",
+			source:   "record Box(v)\nb := \"x\" |> Box\nb.v",
+			expected: "x",
+		},
+		{
+			name:     "Chained pipelines",
+			source:   "record Box(v)\nb := 1 |> Box |> Box()\nb.v.v",
+			expected: float64(1),
+		},
+		{
+			name:     "Pipeline binds looser than or",
+			source:   "record Box(v)\nb := null or 5 |> Box\nb.v",
+			expected: float64(5),
+		},
+
+		// Enums
+		{
+			name:     "Enum payload access",
+			source:   "enum Shape = Circle(r) | Rect(w, h)\ns := Rect(2, 3)\ns.w * s.h",
+			expected: float64(6),
+		},
+		{
+			name:     "Enum structural equality",
+			source:   "enum Shape = Circle(r) | Rect(w, h)\nCircle(1) == Circle(1) and Circle(1) ~= Circle(2) and Circle(1) ~= Rect(1, 1)",
+			expected: true,
+		},
+		{
+			name:     "Nullary variants",
+			source:   "enum Color = Red | Green\nc := Color.Green\nc == Green and c ~= Red",
+			expected: true,
+		},
+		{
+			name:     "Match binds the payload",
+			source:   "enum Shape = Circle(r) | Rect(w, h)\nmatch Rect(2, 5)\ncase Circle(r) then area := r * r\ncase Rect(w, h) then area := w * h\nend\narea",
+			expected: float64(10),
+		},
+		{
+			name:     "Match falls back to else",
+			source:   "enum Color = Red | Green | Blue\nname := \"\"\nmatch Blue\ncase Red then name := \"red\"\nelse name := \"other\"\nend\nname",
+			expected: "other",
+		},
+
+		// Eval and AST values
+		{
+			name:     "Eval a string in the current environment",
+			source:   "x := 2\neval(\"y := x * 21\")\ny",
+			expected: float64(42),
+		},
+		{
+			name:     "Eval returns the last value",
+			source:   "eval(\"1 + 1\n2 + 2\")",
+			expected: float64(4),
+		},
+		{
+			name:     "Inspect a parsed tree",
+			source:   "e := parse(\"a + 2 * 3\").stmts[0]\ne.kind ++ e.op ++ e.right.kind ++ e.left.name",
+			expected: "BinOp+BinOpa",
+		},
+		{
+			name:     "Eval a parsed expression",
+			source:   "a := 4\neval(parse(\"a + 2 * 3\").stmts[0])",
+			expected: float64(10),
+		},
+		{
+			name:     "Construct and eval a tree",
+			source:   "n := ast_node(\"Integer\", {\"value\": 40})\nsum := ast_node(\"BinOp\", {\"op\": \"+\", \"left\": n, \"right\": n})\neval(sum) + sum.line",
+			expected: float64(82),
+		},
+		{
+			name:     "Syntax errors in eval and parse are error values",
+			source:   "[eval(\"1 +\"), parse(\"(1\"), eval(\"x := @\")] |> map(str) |> str",
+			expected: `["<error syntax error at line 1: Found + at the end of parsing>", "<error syntax error at line 1: ')' expected.>", "<error syntax error at line 1: Error at @: Unexpected character.>"]`,
+		},
+		{
+			name:     "Omitted blocks are empty",
+			source:   "n := ast_node(\"IfStmt\", {\"condition\": parse(\"true\")})\neval(n)\nstr(n)",
+			expected: "IfStmt(Stmts([Bool[true]]), then:Stmts([]), else:nil)",
+		},
+		{
+			name:     "eval of empty and comment-only source",
+			source:   "str([eval(\"\"), parse(\"# only a comment\"), eval(\"# nothing\")])",
+			expected: "[null, Stmts([]), null]",
+		},
+		{
+			name:     "eval of an assignment is null",
+			source:   "r := eval(\"x := 1\")\nr == null and type(r) == \"null\" and x == 1",
+			expected: true,
+		},
+
+		// Variables and constants
+		{
+			name:     "Variable assignment",
+			source:   "x := 3\nx := x + 1\nx * 2",
+			expected: float64(8),
+		},
+		{
+			name:     "Const binding",
+			source:   "const LIMIT := 100\nLIMIT / 4",
+			expected: float64(25),
+		},
+		{
+			name:     "Annotated assignment",
+			source:   "x: int := 3\nconst NAME: string := \"inky\"\nx + 1",
+			expected: float64(4),
+		},
+
+		// Records
+		{
+			name:     "Record field read",
+			source:   "record Point(x, y)\np := Point(1, 2)\np.x + p.y",
+			expected: float64(3),
+		},
+		{
+			name:     "Record field write",
+			source:   "record Point(x, y)\np := Point(1, 2)\np.y := \"two\"\np.y",
+			expected: "two",
+		},
+		{
+			name:     "Records are shared by reference",
+			source:   "record Box(v)\na := Box(1)\nb := a\nb.v := 5\na.v",
+			expected: float64(5),
+		},
+		{
+			name:     "len counts characters, not bytes",
+			source:   "len(\"héllo\") + len([1, 2]) + len({1: 2}) + len(1..<4)",
+			expected: float64(11),
+		},
+		{
+			name:     "type names a value's type",
+			source:   "type([]) ++ \" \" ++ type(1) ++ \" \" ++ type(null)",
+			expected: "list number null",
+		},
+		{
+			name:     "str formats without quotes",
+			source:   "str([\"a\", 1.5])",
+			expected: `["a", 1.5]`,
+		},
+		{
+			name:     "num parses strings and bools",
+			source:   "num(\" 2.5 \") + num(true) + float(\"1e1\")",
+			expected: float64(13.5),
+		},
+		{
+			name:     "int truncates toward zero",
+			source:   "str(int(-2.7)) ++ str(int(\"3.9\")) ++ str(int(-0.5))",
+			expected: "-230",
+		},
+		{
+			name:     "bool follows truthiness",
+			source:   "bool([]) or bool(\"x\")",
+			expected: true,
+		},
+		{
+			name:     "strings case and trimming",
+			source:   "strings.upper(strings.trim(\"  héllo \")) ++ strings.lower(\"AB\") ++ strings.trim(\"--x-\", \"-\")",
+			expected: "HÉLLOabx",
+		},
+		{
+			name:     "strings split and join",
+			source:   "strings.join(strings.split(\"a,b,,c\", \",\"), \"|\") ++ strings.join(strings.split(\" x  y \"))",
+			expected: "a|b||cxy",
+		},
+		{
+			name:     "strings find counts characters",
+			source:   "strings.find(\"héllo\", \"l\") * 10 + strings.find(\"abc\", \"z\")",
+			expected: float64(19),
+		},
+		{
+			name:     "strings replace with and without a count",
+			source:   "strings.replace(\"aaa\", \"a\", \"b\") ++ strings.replace(\"aaa\", \"a\", \"b\", 1)",
+			expected: "bbbbaa",
+		},
+		{
+			name:     "strings prefix and suffix",
+			source:   "strings.starts_with(\"inky\", \"in\") and strings.ends_with(\"inky\", \"ky\")",
+			expected: true,
+		},
+		{
+			name:     "strings repeat and padding",
+			source:   "strings.repeat(\"ab\", 2) ++ strings.pad_left(\"é\", 3, \"*\") ++ strings.pad_right(\"x\", 2) ++ \"|\"",
+			expected: "abab**éx |",
+		},
+		{
+			name:     "strings len and sub are rune-aware",
+			source:   "strings.sub(\"héllo\", 1, 3) ++ strings.sub(\"héllo\", -2) ++ str(strings.len(\"héllo\"))",
+			expected: "éllo5",
+		},
+		{
+			name:     "math constants and roots",
+			source:   "math.sqrt(16) + math.floor(math.pi) + math.ceil(math.e)",
+			expected: float64(10),
+		},
+		{
+			name:     "math trig",
+			source:   "math.round(math.sin(math.pi / 2) + math.cos(0) + math.atan2(1, 1) * 4 / math.pi, 6)",
+			expected: float64(3),
+		},
+		{
+			name:     "math log with a base",
+			source:   "math.log(1000, 10) + math.log(math.exp(2))",
+			expected: float64(5),
+		},
+		{
+			name:     "math rounding is half away from zero",
+			source:   "math.round(2.5) + math.round(-2.5) + math.round(1.234, 2) + math.abs(-1)",
+			expected: float64(2.23),
+		},
+		{
+			name:     "math rounding to extreme digits",
+			source:   "str([math.round(2.5, 400), math.round(-123.4, -400), math.round(1250, -2), math.round(math.inf, -400)])",
+			expected: "[2.5, -0, 1300, +Inf]",
+		},
+		{
+			name:     "math min, max and clamp",
+			source:   "math.min(3, 1, 2) * 100 + math.max(4, 9) * 10 + math.clamp(15, 0, 5)",
+			expected: float64(195),
+		},
+		{
+			name:     "math nan and inf",
+			source:   "math.is_nan(math.nan) and math.is_inf(-math.inf) and ~math.is_nan(1)",
+			expected: true,
+		},
+		{
+			name:     "json decode keeps key order",
+			source:   "doc := json.decode('{\"b\": [1, 2.5, true, null], \"a\": {\"s\": \"x<y\"}}')\nstr(doc)",
+			expected: `{"b": [1, 2.5, true, null], "a": {"s": "x<y"}}`,
+		},
+		{
+			name:     "json round trip",
+			source:   "text := '{\"z\":1,\"a\":[\"\\u00e9\",{}],\"m\":null}'\njson.encode(json.decode(text))",
+			expected: `{"z":1,"a":["é",{}],"m":null}`,
+		},
+		{
+			name:     "json encodes records and stringifies keys",
+			source:   "record Point(x, y)\njson.encode({1: Point(0.5, -2), true: \"t\"})",
+			expected: `{"1":{"x":0.5,"y":-2},"true":"t"}`,
+		},
+		{
+			name:     "json indent",
+			source:   "json.encode({\"a\": [1]}, {\"indent\": 2})",
+			expected: "{\n  \"a\": [\n    1\n  ]\n}",
+		},
+		{
+			name:     "Malformed json is an error value",
+			source:   "json.decode('{\"a\": }').message ++ \"|\" ++ json.decode(\"[1] 2\").message",
+			expected: "invalid JSON: missing value after object key|invalid JSON: unexpected data after the JSON value",
+		},
+		{
+			name:     "re match",
+			source:   "re.match(\"b+\", \"abbc\") and ~re.match(\"^b+$\", \"abbc\")",
+			expected: true,
+		},
+		{
+			name:     "re find with groups",
+			source:   "m := re.find(\"(?P<key>\\w+)=(\\d+)?\", \"é key=\")\nm.text ++ str(m.start) ++ str(m.groups) ++ str(m.named)",
+			expected: `key=2["key", null]{"key": "key"}`,
+		},
+		{
+			name:     "re find without a match",
+			source:   "re.find(\"x\", \"abc\") == null",
+			expected: true,
+		},
+		{
+			name:     "re find_all with a compiled pattern",
+			source:   "word := re.compile(\"[a-z]+\")\nstrings.join([m.text for m in re.find_all(word, \"one, two; three\")], \"|\") ++ \" \" ++ word.pattern",
+			expected: "one|two|three [a-z]+",
+		},
+		{
+			name:     "re replace with a template",
+			source:   "re.replace(\"(?P<first>\\w+) (\\w+)\", \"hello world\", \"$2 ${first}\")",
+			expected: "world hello",
+		},
+		{
+			name:     "re replace with a callback",
+			source:   "re.replace(\"[aeiou]\", \"banana\", strings.upper)",
+			expected: "bAnAnA",
+		},
+		{
+			name:     "Invalid patterns are error values",
+			source:   "re.compile(\"(a\").message ++ \" | \" ++ re.find(\"[\", \"x\").message",
+			expected: "error parsing regexp: missing closing ): `(a` | error parsing regexp: missing closing ]: `[`",
+		},
+		{
+			name:     "Values that contain themselves print abbreviated",
+			source:   "xs := [1]\nxs[0] := xs\nm := {\"a\": 1}\nm[\"self\"] := m\nm[\"xs\"] := xs\nrecord P(v)\np := P(0)\np.v := [p]\nstr(xs) ++ str(m) ++ str(p)",
+			expected: `[[...]]{"a": 1, "self": {...}, "xs": [[...]]}P{v: [P{...}]}`,
+		},
+		{
+			name:     "Values that contain themselves compare",
+			source:   "xs := [1]\nxs[0] := xs\nys := [1]\nys[0] := ys\nstr([xs == xs, xs == ys, [1, xs] == [2, ys], xs in [ys]])",
+			expected: "[true, true, false, true]",
+		},
+		{
+			name:     "map and filter in a pipeline",
+			source:   "[3, 0, 12, 5] |> filter(bool) |> map(str) |> str",
+			expected: `["3", "12", "5"]`,
+		},
+		{
+			name:     "map with a record type",
+			source:   "record Box(v)\nmap(1..3, Box)[1].v",
+			expected: float64(2),
+		},
+		{
+			name:     "reduce with and without an initial value",
+			source:   "str([reduce([4, 9, 2], math.max), reduce([], math.max, -1)])",
+			expected: "[9, -1]",
+		},
+		{
+			name:     "any and all",
+			source:   "str([any([0, null, 1]), all([]), any([\"\", \"\"], len), all({\"a\": 1}, bool)])",
+			expected: "[true, true, false, true]",
+		},
+		{
+			name:     "zip stops at the shortest",
+			source:   "str(zip([1, 2, 3], \"ab\", 0..10))",
+			expected: `[[1, "a", 0], [2, "b", 1]]`,
+		},
+		{
+			name:     "enumerate from a start",
+			source:   "str(enumerate([\"a\", \"b\"], 1))",
+			expected: `[[1, "a"], [2, "b"]]`,
+		},
+		{
+			name:     "reverse and unique",
+			source:   "reverse(\"héllo\") ++ str(unique([1, 2, 1, [1], [1], \"a\"]) |> reverse)",
+			expected: `olléh["a", [1], 2, 1]`,
+		},
+		{
+			name:     "group_by keeps first appearance order",
+			source:   "str(group_by([\"bb\", \"a\", \"cc\", \"d\"], len))",
+			expected: `{2: ["bb", "cc"], 1: ["a", "d"]}`,
+		},
+		{
+			name:     "sort naturally and by key",
+			source:   "str([sort([3, 1, 2]), sort([\"b\", \"a\"]), sort([\"ccc\", \"a\", \"bb\", \"d\"], {\"key\": len, \"reverse\": true})])",
+			expected: `[[1, 2, 3], ["a", "b"], ["ccc", "bb", "a", "d"]]`,
+		},
+		{
+			name:     "sort is stable and does not modify its argument",
+			source:   "xs := [\"b\", \"A\", \"a\", \"B\"]\nstr(sort(xs, {\"key\": strings.lower})) ++ str(xs)",
+			expected: `["A", "a", "b", "B"]["b", "A", "a", "B"]`,
+		},
 	}
 
 	for _, test := range tests {
@@ -259,3 +816,935 @@ func TestE2E(t *testing.T) {
 		})
 	}
 }
+
+func TestE2EErrors(t *testing.T) {
+	tests := []TestCase{
+		{
+			name:     "Unknown record field",
+			source:   "record Point(x, y)\np := Point(1, 2)\np.z",
+			expected: `record Point has no field "z" at line 3`,
+		},
+		{
+			name:     "Unknown record field write",
+			source:   "record Point(x, y)\np := Point(1, 2)\np.z := 3",
+			expected: `record Point has no field "z" at line 3`,
+		},
+		{
+			name:     "Constant list element write",
+			source:   "const P := [1, 2]\nP[0] := 9",
+			expected: "cannot assign to an index of a constant list at line 2",
+		},
+		{
+			name:     "Constant record field write",
+			source:   "record Point(x, y)\nconst P := Point(1, 2)\nP.x := 9",
+			expected: `cannot assign to field "x" of a constant Point at line 3`,
+		},
+		{
+			name:     "Nested write through a constant",
+			source:   "const M := {\"a\": [1, {\"b\": 2}]}\nq := M[\"a\"][1]\nq[\"b\"] := 3",
+			expected: "cannot assign to a key of a constant map at line 3",
+		},
+		{
+			name:     "Constant containing itself",
+			source:   "l := [1]\nl[0] := l\nconst L := l\nl[0][0] := 2",
+			expected: "cannot assign to an index of a constant list at line 4",
+		},
+		{
+			name:     "Record arity",
+			source:   "record Point(x, y)\nPoint(1)",
+			expected: "record Point expects 2 fields, got 1 at line 2",
+		},
+		{
+			name:     "String index out of range",
+			source:   "\"abc\"[3]",
+			expected: "index 3 out of range for string of length 3 at line 1",
+		},
+		{
+			name:     "Unknown variant field",
+			source:   "enum Shape = Circle(r)\nCircle(1).d",
+			expected: `variant Circle has no field "d" at line 2`,
+		},
+		{
+			name:     "Variant arity",
+			source:   "enum Shape = Rect(w, h)\nRect(1)",
+			expected: "variant Rect expects 2 values, got 1 at line 2",
+		},
+		{
+			name:     "Match without a matching case",
+			source:   "enum Color = Red | Green\nc := Green\nenum Other = Red\nmatch c\ncase Red then println 1\nend",
+			expected: "no case matches Green at line 4",
+		},
+		{
+			name:     "Unknown AST kind",
+			source:   "ast_node(\"Loop\", {})",
+			expected: `unknown AST node kind "Loop" at line 1`,
+		},
+		{
+			name:     "Mistyped AST field",
+			source:   "ast_node(\"UnOp\", {\"operand\": 1})",
+			expected: "UnOp.operand: expected ast.Expr, got TYPE_NUMBER at line 1",
+		},
+		{
+			name:     "Unknown AST field",
+			source:   "parse(\"1\").stmts[0].name",
+			expected: `Integer node has no field "name" at line 1`,
+		},
+		{
+			name:     "Missing AST child",
+			source:   "ast_node(\"BinOp\", {\"op\": \"+\", \"left\": parse(\"1\")})",
+			expected: `BinOp node needs field "right" at line 1`,
+		},
+		{
+			name:     "Missing AST clause",
+			source:   "ast_node(\"ListComp\", {\"element\": parse(\"1\")})",
+			expected: `ListComp node needs field "clause" at line 1`,
+		},
+		{
+			name:     "Null AST list element",
+			source:   "ast_node(\"Call\", {\"callee\": parse(\"f\"), \"args\": [null]})",
+			expected: `Call node field "args" cannot contain null at line 1`,
+		},
+		{
+			name:     "AST clause without vars",
+			source:   "c := ast_node(\"CompClause\", {\"iterable\": parse(\"[1]\")})",
+			expected: "CompClause node needs 1 or 2 vars, got 0 at line 1",
+		},
+		{
+			name:     "List index out of range",
+			source:   "xs := [1]\nxs[1] := 2",
+			expected: "index 1 out of range for list of length 1 at line 2",
+		},
+		{
+			name:     "Comprehension variable is out of scope afterwards",
+			source:   "ys := [y for y in [1]]\ny",
+			expected: `undefined variable "y" at line 2`,
+		},
+		{
+			name:     "Unhashable map key",
+			source:   "{[1]: 2}",
+			expected: "map keys must be numbers, strings, bools or null, got TYPE_LIST at line 1",
+		},
+		{
+			name:     "Slice step zero",
+			source:   "\"abc\"[::0]",
+			expected: "slice step cannot be zero at line 1",
+		},
+		{
+			name:     "Fractional index",
+			source:   "\"abc\"[1.5]",
+			expected: "index must be an integer, got 1.5 at line 1",
+		},
+		{
+			name:     "Builtin arity",
+			source:   "x := 1\nlen(x, x)",
+			expected: "len expects 1 argument, got 2 at line 2",
+		},
+		{
+			name:     "Builtin argument type",
+			source:   "parse(1)",
+			expected: "parse expects argument 1 to be TYPE_STRING, got TYPE_NUMBER at line 1",
+		},
+		{
+			name:     "Failed number conversion",
+			source:   "\n\nnum(\"12abc\")",
+			expected: `cannot convert "12abc" to a number at line 3`,
+		},
+		{
+			name:     "Failed integer conversion",
+			source:   "int(\"NaN\")",
+			expected: "cannot convert NaN to an integer at line 1",
+		},
+		{
+			name:     "len of a number",
+			source:   "len(5)",
+			expected: "len expects a string, list, map or range, got TYPE_NUMBER at line 1",
+		},
+		{
+			name:     "Unknown module member",
+			source:   "strings.title(\"a\")",
+			expected: `module strings has no member "title" at line 1`,
+		},
+		{
+			name:     "Module function arity",
+			source:   "strings.pad_left(\"a\")",
+			expected: "strings.pad_left expects 2 to 3 arguments, got 1 at line 1",
+		},
+		{
+			name:     "Joining non-strings",
+			source:   "strings.join([\"a\", 1], \",\")",
+			expected: "strings.join expects a list of strings, got TYPE_NUMBER at position 1 at line 1",
+		},
+		{
+			name:     "Fractional repeat count",
+			source:   "strings.repeat(\"a\", 1.5)",
+			expected: "strings.repeat expects an integer count, got 1.5 at line 1",
+		},
+		{
+			name:     "strings repeat too long",
+			source:   "strings.repeat(\"ab\", 5000000000000000)",
+			expected: "strings.repeat result would be longer than 1073741824 bytes at line 1",
+		},
+		{
+			name:     "strings pad too wide",
+			source:   "strings.pad_left(\"a\", 9000000000000000)",
+			expected: "strings.pad_left width cannot exceed 1073741824, got 9000000000000000 at line 1",
+		},
+		{
+			name:     "Integer argument out of range",
+			source:   "strings.repeat(\"a\", 2 ^ 60)",
+			expected: "strings.repeat count 1.152921504606847e+18 is out of range at line 1",
+		},
+		{
+			name:     "Square root of a negative number",
+			source:   "math.sqrt(-1)",
+			expected: "math.sqrt is undefined for -1 at line 1",
+		},
+		{
+			name:     "Logarithm of zero",
+			source:   "math.log(0)",
+			expected: "math.log is undefined for 0 at line 1",
+		},
+		{
+			name:     "Arc sine out of range",
+			source:   "math.asin(2)",
+			expected: "math.asin is undefined for 2 at line 1",
+		},
+		{
+			name:     "Clamp with crossed bounds",
+			source:   "math.clamp(1, 5, 0)",
+			expected: "math.clamp lower bound 5 is greater than upper bound 0 at line 1",
+		},
+		{
+			name:     "Variadic arity",
+			source:   "math.max()",
+			expected: "math.max expects at least 1 argument, got 0 at line 1",
+		},
+		{
+			name:     "Exit status out of range",
+			source:   "os.exit(256)",
+			expected: "os.exit status must be between 0 and 255, got 256 at line 1",
+		},
+		{
+			name:     "Encoding a cyclic value",
+			source:   "xs := [1]\nxs[0] := {\"self\": xs}\njson.encode(xs)",
+			expected: "json.encode cannot encode a value that contains itself at line 3",
+		},
+		{
+			name:     "Encoding a builtin",
+			source:   "json.encode([len])",
+			expected: "json.encode cannot encode TYPE_BUILTIN at line 1",
+		},
+		{
+			name:     "Encoding NaN",
+			source:   "json.encode(math.nan)",
+			expected: "json.encode cannot encode NaN at line 1",
+		},
+		{
+			name:     "json indent out of range",
+			source:   "json.encode([1], {\"indent\": 1000000000000000})",
+			expected: "json.encode option \"indent\" must be between 0 and 16, got 1000000000000000 at line 1",
+		},
+		{
+			name:     "json keys that collide",
+			source:   "json.encode({1: \"a\", \"1\": \"b\"})",
+			expected: "json.encode cannot encode both 1 and \"1\" as the key \"1\" at line 1",
+		},
+		{
+			name:     "proc timeout out of range",
+			source:   "proc.run(\"true\", [], {\"timeout\": 10 ^ 12})",
+			expected: "proc.run option timeout cannot exceed 9.223372036e+09 seconds, got 1e+12 at line 1",
+		},
+		{
+			name:     "proc timeout NaN",
+			source:   "proc.run(\"true\", [], {\"timeout\": math.nan})",
+			expected: "proc.run option \"timeout\" must be a positive number of seconds, got TYPE_NUMBER at line 1",
+		},
+		{
+			name:     "re replace callback returning a non-string",
+			source:   "re.replace(\"a\", \"abc\", len)",
+			expected: "re.replace callback must return a string, got TYPE_NUMBER at line 1",
+		},
+		{
+			name:     "map with a non-callable",
+			source:   "map([1], 2)",
+			expected: "map expects a function, got TYPE_NUMBER at line 1",
+		},
+		{
+			name:     "filter over a non-iterable",
+			source:   "filter(3, bool)",
+			expected: "cannot iterate over TYPE_NUMBER at line 1",
+		},
+		{
+			name:     "reduce of an empty list",
+			source:   "reduce([], math.max)",
+			expected: "reduce of an empty collection needs an initial value at line 1",
+		},
+		{
+			name:     "Errors from callbacks propagate",
+			source:   "[1, \"a\"] |> map(strings.upper)",
+			expected: "strings.upper expects argument 1 to be TYPE_STRING, got TYPE_NUMBER at line 1",
+		},
+		{
+			name:     "sort of mixed types",
+			source:   "sort([1, \"a\"])",
+			expected: "cannot compare TYPE_STRING and TYPE_NUMBER at line 1",
+		},
+		{
+			name:     "sort with both key and compare",
+			source:   "sort([1, 2], {\"compare\": math.max, \"key\": str})",
+			expected: "sort takes a key or a compare function, not both, at line 1",
+		},
+		{
+			name:     "sort with an unknown option",
+			source:   "sort([1], {\"by\": len})",
+			expected: `sort has no option "by" at line 1`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens := lexer.NewLexer([]byte(test.source)).Tokenize()
+			ast := parser.NewParser(tokens).Parse()
+			interpreter := interpreter.NewInterpreter()
+
+			_, _, err := interpreter.Interpret(ast)
+			if err == nil {
+				t.Fatalf("Expected error %q, got none", test.expected)
+			}
+			if err.Error() != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, err.Error())
+			}
+		})
+	}
+}
+
+func TestConstReassignAtRuntime(t *testing.T) {
+	// Each chunk is parsed separately, as in the REPL, so only the
+	// interpreter can see that LIMIT was declared const.
+	interpreter := interpreter.NewInterpreter()
+	chunks := []string{"const LIMIT := 100", "\nLIMIT := 5"}
+	var err error
+	for _, chunk := range chunks {
+		tokens := lexer.NewLexer([]byte(chunk)).Tokenize()
+		_, _, err = interpreter.Interpret(parser.NewParser(tokens).Parse())
+	}
+	if err == nil {
+		t.Fatal("Expected an error when reassigning a constant")
+	}
+	expected := `cannot reassign constant "LIMIT" declared at line 1, at line 2`
+	if err.Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, err.Error())
+	}
+}
+
+func TestConstScopes(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected string // the result, or the parse or runtime error
+	}{
+		{
+			name:     "Const in an untaken branch",
+			source:   "if false then\nconst X := 1\nend\nX := 2\nX",
+			expected: "2",
+		},
+		{
+			name:     "Const in a taken branch",
+			source:   "if true then\nconst X := 1\nend\nX := 2",
+			expected: `cannot reassign constant "X" declared at line 2, at line 4`,
+		},
+		{
+			name:     "Const reassigned in a nested block",
+			source:   "const X := 1\nif true then\nX := 2\nend",
+			expected: `line 3: Cannot reassign constant "X" declared at line 1.`,
+		},
+		{
+			name:     "Const in a macro body",
+			source:   "macro m()\nconst tmp := 1\nend\nm()\ntmp := 5\ntmp",
+			expected: "5",
+		},
+		{
+			name:     "Outer const named in a macro body",
+			source:   "const tmp := 1\nmacro m()\ntmp := 2\nend\nm()\ntmp",
+			expected: "1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := func() (result string, err error) {
+				defer func() {
+					if r := recover(); r != nil {
+						err = r.(error)
+					}
+				}()
+				p := parser.NewParser(lexer.NewLexer([]byte(test.source)).Tokenize())
+				p.OnError = func(msg string, line int) {
+					panic(fmt.Errorf("line %d: %s", line, msg))
+				}
+				expanded, err := macro.NewExpander().Expand(p.Parse())
+				if err != nil {
+					return "", err
+				}
+				_, val, err := interpreter.NewInterpreter().Interpret(expanded)
+				return fmt.Sprint(val), err
+			}()
+			if err != nil {
+				result = err.Error()
+			}
+			if result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestTypeCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected []string
+	}{
+		{
+			name:     "Well typed",
+			source:   "x: int := 3\ny: float := x / 2\nname: string := \"a\" ++ x",
+			expected: nil,
+		},
+		{
+			name:     "Unannotated code stays dynamic",
+			source:   "x := 3\nx := \"three\"\nx * 2",
+			expected: nil,
+		},
+		{
+			name:     "Annotation mismatch",
+			source:   "x: int := \"three\"",
+			expected: []string{`[Line 1]: cannot assign string to "x" of type int`},
+		},
+		{
+			name:     "Reassignment keeps the annotation",
+			source:   "x: int := 1\nx := true",
+			expected: []string{`[Line 2]: cannot assign bool to "x" of type int`},
+		},
+		{
+			name:     "Operator misuse on annotated values",
+			source:   "s: string := \"a\"\nn: int := 1\nprintln s - n\nprintln s < n",
+			expected: []string{"[Line 3]: unsupported operator - between string and int", "[Line 4]: unsupported operator < between string and int"},
+		},
+		{
+			name:     "Unknown type",
+			source:   "x: strng := \"a\"",
+			expected: []string{`[Line 1]: unknown type "strng"`},
+		},
+		{
+			name:     "Comprehension variables shadow annotations",
+			source:   "x: int := 1\nys := [x ++ \"!\" for x in [\"a\"]]\nprintln x + 1",
+			expected: nil,
+		},
+		{
+			name:     "Adding a string and a number",
+			source:   "n: int := 1\nprintln \"n=\" + n",
+			expected: []string{"[Line 2]: unsupported operator + between string and int"},
+		},
+		{
+			name:     "Powers are ints only for non-negative exponents",
+			source:   "n: int := 2\na: int := 2 ^ 3\nb: int := 2 ^ n\nc: int := 2 ^ (-1)",
+			expected: []string{`[Line 3]: cannot assign float to "b" of type int`, `[Line 4]: cannot assign float to "c" of type int`},
+		},
+		{
+			name:     "Map keys need not be ints",
+			source:   "m := {\"a\": 1}\nprintln m[\"a\"]\nprintln {true: 2}[true]",
+			expected: nil,
+		},
+		{
+			name:     "String and list indexes must be ints",
+			source:   "s: string := \"abc\"\nprintln s[\"a\"]\nprintln [1, 2][\"a\"]",
+			expected: []string{"[Line 2]: index must be int, got string", "[Line 3]: index must be int, got string"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens := lexer.NewLexer([]byte(test.source)).Tokenize()
+			errors := checker.NewChecker().Check(parser.NewParser(tokens).Parse())
+
+			if len(errors) != len(test.expected) {
+				t.Fatalf("Expected %d errors, got %v", len(test.expected), errors)
+			}
+			for i, err := range errors {
+				if err.Error() != test.expected[i] {
+					t.Errorf("Expected %q, got %q", test.expected[i], err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestMacros(t *testing.T) {
+	const defs = "macro unless(cond, body)\nif cond then else body end\nend\n" +
+		"macro swap(a, b)\ntmp := a\na := b\nb := tmp\nend\n" +
+		"macro twice(x)\nx * 2\nend\n"
+	tests := []TestCase{
+		{
+			name:     "Statement macro with a do block",
+			source:   defs + "x := 0\nunless(x > 5) do\nx := 7\nend\nx",
+			expected: float64(7),
+		},
+		{
+			name:     "Expression macro keeps argument grouping",
+			source:   defs + "twice(1 + 2)",
+			expected: float64(6),
+		},
+		{
+			name:     "Macro temporaries do not capture caller variables",
+			source:   defs + "tmp := 1\nother := 2\nswap(tmp, other)\ntmp * 10 + other",
+			expected: float64(21),
+		},
+		{
+			name:     "Macros expand inside other macros",
+			source:   defs + "unless(false) do\ny := twice(twice(5))\nend\ny",
+			expected: float64(20),
+		},
+		{
+			name:     "eval expands the script's macros and its own",
+			source:   defs + "m := eval(\"macro inc(v)\nv + 1\nend\")\neval(\"inc(twice(3))\")",
+			expected: float64(7),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens := lexer.NewLexer([]byte(test.source)).Tokenize()
+			interpreter := interpreter.NewInterpreter()
+			ast, err := interpreter.Expander.Expand(parser.NewParser(tokens).Parse())
+			if err != nil {
+				t.Fatalf("Macro error: %v", err)
+			}
+
+			_, result, err := interpreter.Interpret(ast)
+			if err != nil {
+				t.Fatalf("Interpreter error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("Expected %v, got %v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestMacroErrors(t *testing.T) {
+	tests := []TestCase{
+		{
+			name:     "Macro arity",
+			source:   "macro twice(x)\nx * 2\nend\n\ntwice(1, 2)",
+			expected: "macro twice expects 1 arguments, got 2 at line 5",
+		},
+		{
+			name:     "Statement macro used as an expression",
+			source:   "macro set(v)\nv := 1\nend\ny := set(x)",
+			expected: "macro set expands to statements and cannot be used in an expression, at line 4",
+		},
+		{
+			name:     "Recursive macro",
+			source:   "macro loop(x)\nloop(x)\nend\nloop(1)",
+			expected: "macro loop expands too deeply, does it use itself? at line 2",
+		},
+		{
+			name:     "Nested macro declaration",
+			source:   "if true then\nmacro m()\n1\nend\nend",
+			expected: "macro m must be declared at the top level, at line 2",
+		},
+		{
+			name:     "Do block passed to a plain call",
+			source:   "record Box(v)\nBox(1) do\n2\nend",
+			expected: "a do block can only be passed to a macro, at line 2",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens := lexer.NewLexer([]byte(test.source)).Tokenize()
+			_, err := macro.NewExpander().Expand(parser.NewParser(tokens).Parse())
+			if err == nil {
+				t.Fatalf("Expected error %q, got none", test.expected)
+			}
+			if err.Error() != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, err.Error())
+			}
+		})
+	}
+}
+
+func TestIO(t *testing.T) {
+	// Sources are format strings; %[1]q is a path in a fresh temp directory
+	tests := []TestCase{
+		{
+			name:     "Write, append and read a file",
+			source:   "io.write_file(%[1]q, \"a\n\")\nio.append_file(%[1]q, \"b\n\")\nio.read_file(%[1]q)",
+			expected: "a\nb\n",
+		},
+		{
+			name:     "Iterate over lines",
+			source:   "io.write_file(%[1]q, \"x\r\ny\nz\")\nstrings.join([l for l in io.lines(%[1]q)], \",\")",
+			expected: "x,y,z",
+		},
+		{
+			name:     "Lines are read as iterated",
+			source:   "io.write_file(%[1]q, \"a\nb\nc\")\nls := io.lines(%[1]q)\nfirst := ls.read_line()\nrest := [l for l in ls]\nfirst ++ str(rest) ++ str(strings.ends_with(ls.read_line().message, \"is closed\"))",
+			expected: `a["b", "c"]true`,
+		},
+		{
+			name:     "File handles",
+			source:   "f := io.open(%[1]q, \"w\")\nf.write(\"one\ntwo\")\nf.close()\nf := io.open(%[1]q)\nfirst := f.read_line()\nfirst ++ \"|\" ++ f.read() ++ \"|\" ++ str(f.read_line())",
+			expected: "one|two|null",
+		},
+		{
+			name:     "Failures are error values",
+			source:   "text := io.read_file(%[1]q)\nif text then text := \"found\" else text := text.message end\ntext",
+			expected: "open %[1]s: no such file or directory",
+		},
+		{
+			name:     "Writing to a closed file",
+			source:   "f := io.open(%[1]q, \"w\")\nf.close()\nf.write(\"x\").message",
+			expected: "file %[1]s is closed",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file.txt")
+			source := fmt.Sprintf(test.source, path)
+			tokens := lexer.NewLexer([]byte(source)).Tokenize()
+			interpreter := interpreter.NewInterpreter()
+			defer interpreter.Close()
+
+			_, result, err := interpreter.Interpret(parser.NewParser(tokens).Parse())
+			if err != nil {
+				t.Fatalf("Interpreter error: %v", err)
+			}
+			expected := test.expected
+			if text, ok := expected.(string); ok && strings.Contains(text, "%[1]") {
+				expected = fmt.Sprintf(text, path)
+			}
+			if result != expected {
+				t.Errorf("Expected %q, got %q", expected, result)
+			}
+		})
+	}
+}
+
+func TestIOReadLineAndClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+	interpreter := interpreter.NewInterpreter()
+	interpreter.Stdin = bufio.NewReader(strings.NewReader("first\nsecond"))
+
+	chunks := []string{
+		fmt.Sprintf("f := io.open(%q, \"a\")\n[io.read_line(), io.read_line(), io.read_line()]", path),
+		"f.write(\"x\")",
+	}
+	var results []any
+	for idx, chunk := range chunks {
+		tokens := lexer.NewLexer([]byte(chunk)).Tokenize()
+		_, result, err := interpreter.Interpret(parser.NewParser(tokens).Parse())
+		if err != nil {
+			t.Fatalf("Interpreter error: %v", err)
+		}
+		results = append(results, result)
+		if idx == 0 {
+			// The host closes handles the script left open when it ends
+			interpreter.Close()
+		}
+	}
+
+	if got := fmt.Sprint(results[0]); got != `["first", "second", null]` {
+		t.Errorf("Expected the stdin lines then null, got %s", got)
+	}
+	if got := fmt.Sprint(results[1]); got != fmt.Sprintf("<error file %s is closed>", path) {
+		t.Errorf("Expected the handle to be closed, got %s", got)
+	}
+}
+
+func TestIOLinesFromStdin(t *testing.T) {
+	interpreter := interpreter.NewInterpreter()
+	interpreter.Stdin = bufio.NewReader(strings.NewReader("first\r\nsecond\n"))
+
+	source := "[strings.upper(l) for l in io.lines()]"
+	tokens := lexer.NewLexer([]byte(source)).Tokenize()
+	_, result, err := interpreter.Interpret(parser.NewParser(tokens).Parse())
+	if err != nil {
+		t.Fatalf("Interpreter error: %v", err)
+	}
+	if got := fmt.Sprint(result); got != `["FIRST", "SECOND"]` {
+		t.Errorf("Expected the stdin lines, got %s", got)
+	}
+}
+
+func TestOS(t *testing.T) {
+	cwd, _ := os.Getwd()
+	t.Setenv("INKY_TEST_VAR", "set")
+
+	tests := []TestCase{
+		{
+			name:     "Script arguments",
+			source:   "strings.join(os.args, \",\")",
+			expected: "a,b c",
+		},
+		{
+			name:     "Read environment variables",
+			source:   "os.getenv(\"INKY_TEST_VAR\") ++ str(os.getenv(\"INKY_TEST_UNSET\")) ++ os.getenv(\"INKY_TEST_UNSET\", \"!\")",
+			expected: "setnull!",
+		},
+		{
+			name:     "Set environment variables",
+			source:   "os.setenv(\"INKY_TEST_VAR\", \"changed\")\nos.getenv(\"INKY_TEST_VAR\")",
+			expected: "changed",
+		},
+		{
+			name:     "Working directory",
+			source:   "os.cwd()",
+			expected: cwd,
+		},
+		{
+			name:     "Host name",
+			source:   "len(os.hostname()) > 0",
+			expected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens := lexer.NewLexer([]byte(test.source)).Tokenize()
+			interpreter := interpreter.NewInterpreter()
+			interpreter.SetArgs([]string{"a", "b c"})
+
+			_, result, err := interpreter.Interpret(parser.NewParser(tokens).Parse())
+			if err != nil {
+				t.Fatalf("Interpreter error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("Expected %v, got %v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestOSExit(t *testing.T) {
+	tests := []struct {
+		source string
+		code   int
+	}{
+		{"os.exit()", 0},
+		{"if true then os.exit(3) end\nprintln \"unreachable\"", 3},
+	}
+
+	for _, test := range tests {
+		tokens := lexer.NewLexer([]byte(test.source)).Tokenize()
+		_, _, err := interpreter.NewInterpreter().Interpret(parser.NewParser(tokens).Parse())
+
+		var exit *interpreter.ExitError
+		if !errors.As(err, &exit) {
+			t.Fatalf("Expected an exit from %q, got %v", test.source, err)
+		}
+		if exit.Code != test.code {
+			t.Errorf("Expected status %d from %q, got %d", test.code, test.source, exit.Code)
+		}
+	}
+}
+
+func TestProc(t *testing.T) {
+	tests := []TestCase{
+		{
+			name:     "Run with stdin",
+			source:   "proc.run(\"cat\", [], {\"stdin\": \"piped\"}).stdout",
+			expected: "piped",
+		},
+		{
+			name:     "Exit code and stderr",
+			source:   "r := proc.run(\"sh\", [\"-c\", \"echo oops >&2; exit 3\"])\nstr(r.code) ++ r.stderr",
+			expected: "3oops\n",
+		},
+		{
+			name:     "Environment and working directory",
+			source:   "proc.run(\"sh\", [\"-c\", \"echo $GREETING; pwd\"], {\"env\": {\"GREETING\": \"hi\"}, \"cwd\": \"/\"}).stdout",
+			expected: "hi\n/\n",
+		},
+		{
+			name:     "Stream output lines",
+			source:   "p := proc.start(\"echo\", [\"a\nb\"])\nfirst := p.read_line()\nrest := [l for l in p]\nfirst ++ str(rest) ++ str(p.wait().code)",
+			expected: `a["b"]0`,
+		},
+		{
+			name:     "Kill a running process",
+			source:   "p := proc.start(\"sleep\", [\"10\"])\np.kill()\np.wait().code",
+			expected: float64(-1),
+		},
+		{
+			name:     "Timeout",
+			source:   "proc.run(\"sleep\", [\"10\"], {\"timeout\": 0.1}).message",
+			expected: "sleep timed out after 0.1s",
+		},
+		{
+			name:     "Waiting after the deadline for a finished process",
+			source:   "p := proc.start(\"true\", [], {\"timeout\": 0.1})\nr := proc.run(\"sleep\", [\"0.3\"])\np.wait().code",
+			expected: float64(0),
+		},
+		{
+			name:     "Missing command",
+			source:   "bool(proc.run(\"inky-no-such-command\"))",
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens := lexer.NewLexer([]byte(test.source)).Tokenize()
+			interpreter := interpreter.NewInterpreter()
+			defer interpreter.Close()
+
+			_, result, err := interpreter.Interpret(parser.NewParser(tokens).Parse())
+			if err != nil {
+				t.Fatalf("Interpreter error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestProcOutlivingChild(t *testing.T) {
+	// The backgrounded sleep keeps standard output open after sh is gone
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"Kill and wait", "p := proc.start(\"sh\", [\"-c\", \"sleep 6 & echo hi; sleep 30\"])\np.read_line()\np.kill()\np.wait().code"},
+		{"Close at exit", "p := proc.start(\"sh\", [\"-c\", \"sleep 6 & echo hi; sleep 30\"])\np.read_line()"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens := lexer.NewLexer([]byte(test.source)).Tokenize()
+			interpreter := interpreter.NewInterpreter()
+
+			start := time.Now()
+			if _, _, err := interpreter.Interpret(parser.NewParser(tokens).Parse()); err != nil {
+				t.Fatalf("Interpreter error: %v", err)
+			}
+			interpreter.Close()
+			if elapsed := time.Since(start); elapsed > 4*time.Second {
+				t.Errorf("Expected the process to be reaped promptly, took %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestProcPolicy(t *testing.T) {
+	interpreter := interpreter.NewInterpreter()
+	var asked []string
+	interpreter.ProcPolicy = func(cmd string, args []string) error {
+		asked = append(asked, cmd+" "+strings.Join(args, " "))
+		if cmd != "echo" {
+			return errors.New("only echo is allowed")
+		}
+		return nil
+	}
+
+	source := "r := proc.run(\"echo\", [\"ok\"])\nproc.start(\"cat\")"
+	tokens := lexer.NewLexer([]byte(source)).Tokenize()
+	_, _, err := interpreter.Interpret(parser.NewParser(tokens).Parse())
+
+	expected := `proc.start of "cat" denied: only echo is allowed at line 2`
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q, got %v", expected, err)
+	}
+	if strings.Join(asked, ",") != "echo ok,cat " {
+		t.Errorf("Expected the policy to see every call, got %q", asked)
+	}
+}
+
+func TestFS(t *testing.T) {
+	// Each source starts in an empty temp directory bound to dir
+	setup := "mkdir := fs.mkdir_all(path.join(dir, \"logs\", \"old\"))\n" +
+		"w := io.write_file(path.join(dir, \"a.log\"), \"12345\")\n" +
+		"w := io.write_file(path.join(dir, \"logs\", \"b.log\"), \"\")\n" +
+		"w := io.write_file(path.join(dir, \"logs\", \"old\", \"c.log\"), \"\")\n" +
+		"w := io.write_file(path.join(dir, \"logs\", \"notes.txt\"), \"\")\n"
+	tests := []TestCase{
+		{
+			name:     "List a directory",
+			source:   "strings.join(fs.list(dir), \",\")",
+			expected: "a.log,logs",
+		},
+		{
+			name:     "Walk a tree",
+			source:   "n := len(dir) + 1\nstrings.join([p[n:] for p in fs.walk(dir)], \",\")",
+			expected: "a.log,logs,logs/b.log,logs/notes.txt,logs/old,logs/old/c.log",
+		},
+		{
+			name:     "Walking is lazy",
+			source:   "w := fs.walk(path.join(dir, \"logs\", \"old\"))\nw2 := io.write_file(path.join(dir, \"logs\", \"old\", \"d.log\"), \"\")\ntype(w) ++ \" \" ++ strings.join([path.base(p) for p in w], \",\")",
+			expected: "walk c.log,d.log",
+		},
+		{
+			name:     "Walking a missing directory",
+			source:   "bool(fs.walk(path.join(dir, \"missing\")))",
+			expected: false,
+		},
+		{
+			name:     "Glob through a parent directory",
+			source:   "strings.join([path.base(p) for p in fs.glob(dir ++ \"/logs/old/../*.log\")], \",\")",
+			expected: "b.log",
+		},
+		{
+			name:     "Glob at any depth",
+			source:   "strings.join([path.base(p) for p in fs.glob(path.join(dir, \"**\", \"*.log\"))], \",\")",
+			expected: "a.log,b.log,c.log",
+		},
+		{
+			name:     "Glob a single level",
+			source:   "strings.join([path.base(p) for p in fs.glob(dir ++ \"/logs/*\")], \",\")",
+			expected: "b.log,notes.txt,old",
+		},
+		{
+			name:     "Stat a file",
+			source:   "info := fs.stat(path.join(dir, \"a.log\"))\ninfo.name ++ str(info.size) ++ str(info.is_dir)",
+			expected: "a.log5false",
+		},
+		{
+			name:     "Rename and remove",
+			source:   "r := fs.rename(path.join(dir, \"a.log\"), path.join(dir, \"z.log\"))\nr := fs.remove(path.join(dir, \"logs\"), true)\nstrings.join(fs.list(dir), \",\")",
+			expected: "z.log",
+		},
+		{
+			name:     "Failures are error values",
+			source:   "bool(fs.stat(path.join(dir, \"missing\"))) or bool(fs.remove(path.join(dir, \"logs\")))",
+			expected: false,
+		},
+		{
+			name:     "Temp dirs",
+			source:   "tmp := fs.temp_dir(\"inky-test\")\nempty := len(fs.list(tmp)) == 0\nr := fs.remove(tmp)\nempty and ~fs.stat(tmp)",
+			expected: true,
+		},
+		{
+			name:     "Path helpers",
+			source:   "path.join(\"a\", \"b/\", \"../c.tar.gz\") ++ \" \" ++ path.base(\"a/c.tar.gz\") ++ \" \" ++ path.ext(\"a/c.tar.gz\") ++ \" \" ++ path.dir(\"a/c\")",
+			expected: "a/c.tar.gz c.tar.gz .gz a",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source := fmt.Sprintf("dir := %q\n%s%s", t.TempDir(), setup, test.source)
+			tokens := lexer.NewLexer([]byte(source)).Tokenize()
+			interpreter := interpreter.NewInterpreter()
+
+			_, result, err := interpreter.Interpret(parser.NewParser(tokens).Parse())
+			if err != nil {
+				t.Fatalf("Interpreter error: %v", err)
+			}
+			if result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// TYPE_ANY is not a runtime type. In a builtin signature it accepts an
+// argument of any type.
+const TYPE_ANY = "TYPE_ANY"
+
+// Builtin is a function implemented in Go and callable from scripts. Its
+// signature lists the type of each parameter and of the result; arguments
+// are checked against it before Fn runs, so Fn may assume the count and
+// types of args. The last Optional parameters may be omitted, and a
+// Variadic builtin accepts any number of extra arguments of the last
+// parameter's type.
+type Builtin struct {
+	Name     string
+	Params   []string
+	Optional int
+	Variadic bool
+	Result   string
+	Fn       func(i *Interpreter, args []Value, line int) (string, any, error)
+}
+
+func (b *Builtin) String() string {
+	return fmt.Sprintf("<builtin %s>", b.Name)
+}
+
+// Signature describes the builtin, e.g. "len(TYPE_ANY) -> TYPE_NUMBER".
+func (b *Builtin) Signature() string {
+	params := make([]string, len(b.Params))
+	for idx, param := range b.Params {
+		params[idx] = param
+		if idx >= len(b.Params)-b.Optional {
+			params[idx] += "?"
+		}
+	}
+	if b.Variadic {
+		params[len(params)-1] += "..."
+	}
+	return fmt.Sprintf("%s(%s) -> %s", b.Name, strings.Join(params, ", "), b.Result)
+}
+
+// Call checks args against the signature and runs the builtin.
+func (b *Builtin) Call(i *Interpreter, args []Value, line int) (string, any, error) {
+	required := len(b.Params) - b.Optional
+	if len(args) < required || (len(args) > len(b.Params) && !b.Variadic) {
+		expected := fmt.Sprint(required)
+		if b.Variadic {
+			expected = "at least " + expected
+		} else if b.Optional > 0 {
+			expected = fmt.Sprintf("%d to %d", required, len(b.Params))
+		}
+		plural := "s"
+		if required == 1 && b.Optional == 0 {
+			plural = ""
+		}
+		return "", 0, fmt.Errorf("%s expects %s argument%s, got %d at line %d", b.Name, expected, plural, len(args), line)
+	}
+	for idx, arg := range args {
+		param := b.Params[min(idx, len(b.Params)-1)]
+		if param != TYPE_ANY && param != arg.Type {
+			return "", 0, fmt.Errorf("%s expects argument %d to be %s, got %s at line %d", b.Name, idx+1, param, arg.Type, line)
+		}
+	}
+	return b.Fn(i, args, line)
+}
+
+// builtins is the registry of native functions bound in every new interpreter.
+var builtins = map[string]*Builtin{}
+
+// RegisterBuiltin adds a native function to the registry. It must be called
+// before the interpreters that should see it are created, e.g. from init.
+func RegisterBuiltin(builtin *Builtin) {
+	if _, ok := builtins[builtin.Name]; ok {
+		panic(fmt.Sprintf("builtin %s registered twice", builtin.Name))
+	}
+	builtins[builtin.Name] = builtin
+}
+
+// defineBuiltins binds every registered builtin and module in the global
+// environment.
+func (i *Interpreter) defineBuiltins() {
+	for name, builtin := range builtins {
+		i.env.Declare(name, TYPE_BUILTIN, builtin, 0)
+	}
+	for name, module := range modules {
+		i.env.Declare(name, TYPE_MODULE, module, 0)
+	}
+}
+
+func init() {
+	for _, builtin := range []*Builtin{
+		{Name: "len", Params: []string{TYPE_ANY}, Result: TYPE_NUMBER, Fn: builtinLen},
+		{Name: "type", Params: []string{TYPE_ANY}, Result: TYPE_STRING, Fn: builtinType},
+		{Name: "str", Params: []string{TYPE_ANY}, Result: TYPE_STRING, Fn: builtinStr},
+		{Name: "num", Params: []string{TYPE_ANY}, Result: TYPE_NUMBER, Fn: builtinNum},
+		{Name: "int", Params: []string{TYPE_ANY}, Result: TYPE_NUMBER, Fn: builtinInt},
+		{Name: "float", Params: []string{TYPE_ANY}, Result: TYPE_NUMBER, Fn: builtinNum},
+		{Name: "bool", Params: []string{TYPE_ANY}, Result: TYPE_BOOL, Fn: builtinBool},
+	} {
+		RegisterBuiltin(builtin)
+	}
+}
+
+// len(x) is the number of characters in a string or elements in a list,
+// map or range.
+func builtinLen(i *Interpreter, args []Value, line int) (string, any, error) {
+	switch args[0].Type {
+	case TYPE_STRING:
+		return TYPE_NUMBER, float64(utf8.RuneCountInString(args[0].Value.(string))), nil
+	case TYPE_LIST:
+		return TYPE_NUMBER, float64(len(args[0].Value.(*List).Elements)), nil
+	case TYPE_MAP:
+		return TYPE_NUMBER, float64(args[0].Value.(*Map).Len()), nil
+	case TYPE_RANGE:
+		r := args[0].Value.(*Range)
+		return TYPE_NUMBER, float64(max(r.Stop()-r.Start, 0)), nil
+	default:
+		return "", 0, fmt.Errorf("len expects a string, list, map or range, got %s at line %d", args[0].Type, line)
+	}
+}
+
+// type(x) names the type of x, e.g. "number" or "list".
+func builtinType(i *Interpreter, args []Value, line int) (string, any, error) {
+	return TYPE_STRING, strings.ToLower(strings.TrimPrefix(args[0].Type, "TYPE_")), nil
+}
+
+// str(x) formats x as it would be printed.
+func builtinStr(i *Interpreter, args []Value, line int) (string, any, error) {
+	return TYPE_STRING, fmt.Sprintf("%v", args[0].Value), nil
+}
+
+// num(x) and float(x) convert a number, a numeric string or a bool to a
+// number. There is a single number type, so the two are the same.
+func builtinNum(i *Interpreter, args []Value, line int) (string, any, error) {
+	switch args[0].Type {
+	case TYPE_NUMBER:
+		return TYPE_NUMBER, args[0].Value, nil
+	case TYPE_BOOL:
+		if args[0].Value.(bool) {
+			return TYPE_NUMBER, float64(1), nil
+		}
+		return TYPE_NUMBER, float64(0), nil
+	case TYPE_STRING:
+		num, err := strconv.ParseFloat(strings.TrimSpace(args[0].Value.(string)), 64)
+		if err != nil {
+			return "", 0, fmt.Errorf("cannot convert %q to a number at line %d", args[0].Value, line)
+		}
+		return TYPE_NUMBER, num, nil
+	default:
+		return "", 0, fmt.Errorf("cannot convert %s to a number at line %d", args[0].Type, line)
+	}
+}
+
+// int(x) converts x like num and truncates the result toward zero.
+func builtinInt(i *Interpreter, args []Value, line int) (string, any, error) {
+	_, val, err := builtinNum(i, args, line)
+	if err != nil {
+		return "", 0, err
+	}
+	num := val.(float64)
+	if math.IsNaN(num) || math.IsInf(num, 0) {
+		return "", 0, fmt.Errorf("cannot convert %v to an integer at line %d", num, line)
+	}
+	// Adding zero turns the -0 of e.g. int(-0.5) into 0
+	return TYPE_NUMBER, math.Trunc(num) + 0, nil
+}
+
+// bool(x) reports whether x is truthy.
+func builtinBool(i *Interpreter, args []Value, line int) (string, any, error) {
+	return TYPE_BOOL, isTruthy(args[0].Type, args[0].Value), nil
+}
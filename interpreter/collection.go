@@ -0,0 +1,345 @@
+package interpreter
+
+import (
+	"fmt"
+	"inky/ast"
+	"strings"
+)
+
+// Value pairs a runtime value with its type, for storing inside collections.
+type Value struct {
+	Type  string
+	Value any
+}
+
+func (v Value) String() string {
+	return formatValue(v.Type, v.Value)
+}
+
+// List is an ordered, mutable sequence shared by reference.
+type List struct {
+	Elements []Value
+	frozen   bool // reachable from a constant
+}
+
+func (l *List) String() string {
+	return formatValue(TYPE_LIST, l)
+}
+
+// Map is a mutable mapping from scalar keys to values that remembers the
+// order in which keys were first inserted. It is shared by reference.
+type Map struct {
+	keys   []Value
+	values []Value
+	index  map[Value]int // key -> position in keys and values
+	frozen bool          // reachable from a constant
+}
+
+func NewMap() *Map {
+	return &Map{index: map[Value]int{}}
+}
+
+func (m *Map) String() string {
+	return formatValue(TYPE_MAP, m)
+}
+
+// Len returns the number of entries.
+func (m *Map) Len() int {
+	return len(m.keys)
+}
+
+// Get returns the value stored under key.
+func (m *Map) Get(key Value) (Value, bool) {
+	idx, ok := m.index[key]
+	if !ok {
+		return Value{}, false
+	}
+	return m.values[idx], true
+}
+
+// Set stores value under key, keeping the key's original position if it exists.
+func (m *Map) Set(key Value, value Value, line int) error {
+	if !isHashable(key.Type) {
+		return fmt.Errorf("map keys must be numbers, strings, bools or null, got %s at line %d", key.Type, line)
+	}
+	if idx, ok := m.index[key]; ok {
+		m.values[idx] = value
+		return nil
+	}
+	m.index[key] = len(m.keys)
+	m.keys = append(m.keys, key)
+	m.values = append(m.values, value)
+	return nil
+}
+
+func isHashable(typ string) bool {
+	return typ == TYPE_NUMBER || typ == TYPE_STRING || typ == TYPE_BOOL || typ == TYPE_NULL
+}
+
+// equal reports whether two values are equal. Scalars, ranges, lists, maps
+// and enum values compare by value; records and other reference types by identity.
+func equal(a Value, b Value) bool {
+	return valuesEqual(a, b, map[[2]any]bool{})
+}
+
+// valuesEqual compares a and b for equal. comparing holds the pairs of
+// containers being compared further up, which a value that contains
+// itself leads back to; such a pair is equal unless some other part of
+// the two values differs.
+func valuesEqual(a Value, b Value, comparing map[[2]any]bool) bool {
+	if a.Type != b.Type {
+		return false
+	}
+	switch a.Type {
+	case TYPE_LIST, TYPE_MAP, TYPE_ENUM:
+		pair := [2]any{a.Value, b.Value}
+		if a.Value == b.Value || comparing[pair] {
+			return true
+		}
+		comparing[pair] = true
+		defer delete(comparing, pair)
+	}
+	switch a.Type {
+	case TYPE_LIST:
+		left, right := a.Value.(*List).Elements, b.Value.(*List).Elements
+		if len(left) != len(right) {
+			return false
+		}
+		for idx := range left {
+			if !valuesEqual(left[idx], right[idx], comparing) {
+				return false
+			}
+		}
+		return true
+	case TYPE_MAP:
+		left, right := a.Value.(*Map), b.Value.(*Map)
+		if left.Len() != right.Len() {
+			return false
+		}
+		for idx, key := range left.keys {
+			other, ok := right.Get(key)
+			if !ok || !valuesEqual(left.values[idx], other, comparing) {
+				return false
+			}
+		}
+		return true
+	case TYPE_RANGE:
+		return *a.Value.(*Range) == *b.Value.(*Range)
+	case TYPE_ENUM:
+		left, right := a.Value.(*EnumValue), b.Value.(*EnumValue)
+		if left.Variant != right.Variant {
+			return false
+		}
+		for idx := range left.Payload {
+			if !valuesEqual(left.Payload[idx], right.Payload[idx], comparing) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a.Value == b.Value
+	}
+}
+
+// contains implements the 'in' operator.
+func contains(leftType string, leftVal any, rightType string, rightVal any, line int) (bool, error) {
+	switch rightType {
+	case TYPE_RANGE:
+		return leftType == TYPE_NUMBER && rightVal.(*Range).Contains(leftVal.(float64)), nil
+	case TYPE_LIST:
+		for _, elem := range rightVal.(*List).Elements {
+			if equal(Value{leftType, leftVal}, elem) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case TYPE_MAP:
+		_, ok := rightVal.(*Map).Get(Value{leftType, leftVal})
+		return ok, nil
+	case TYPE_STRING:
+		if leftType != TYPE_STRING {
+			return false, fmt.Errorf("'in <string>' requires a string on the left, got %s at line %d", leftType, line)
+		}
+		return strings.Contains(rightVal.(string), leftVal.(string)), nil
+	default:
+		return false, fmt.Errorf("unsupported operator in between %v and %v at line %d", leftType, rightType, line)
+	}
+}
+
+// iterate calls fn once per element of an iterable value, in order.
+// Lists, strings and ranges yield (position, element); maps yield (key, value).
+// Files yield their remaining lines, a process the lines of its output
+// as they are written, and a walk the paths below its root.
+func iterate(typ string, val any, line int, fn func(key Value, value Value) error) error {
+	switch typ {
+	case TYPE_LIST:
+		for idx, elem := range val.(*List).Elements {
+			if err := fn(Value{TYPE_NUMBER, float64(idx)}, elem); err != nil {
+				return err
+			}
+		}
+	case TYPE_MAP:
+		m := val.(*Map)
+		for idx := range m.keys {
+			if err := fn(m.keys[idx], m.values[idx]); err != nil {
+				return err
+			}
+		}
+	case TYPE_STRING:
+		for idx, ch := range []rune(val.(string)) {
+			if err := fn(Value{TYPE_NUMBER, float64(idx)}, Value{TYPE_STRING, string(ch)}); err != nil {
+				return err
+			}
+		}
+	case TYPE_RANGE:
+		r := val.(*Range)
+		for n := r.Start; n < r.Stop(); n++ {
+			if err := fn(Value{TYPE_NUMBER, float64(n - r.Start)}, Value{TYPE_NUMBER, float64(n)}); err != nil {
+				return err
+			}
+		}
+	case TYPE_FILE:
+		f := val.(*File)
+		if err := f.checkReadable(); err != nil {
+			return fmt.Errorf("%v at line %d", err, line)
+		}
+		for idx := 0; ; idx++ {
+			lineType, text, _ := readLine(f.reader)
+			if lineType == TYPE_NULL {
+				break
+			} else if lineType == TYPE_ERROR {
+				return fmt.Errorf("cannot read %s: %s at line %d", f.Path, text.(*Error).Message, line)
+			}
+			if err := fn(Value{TYPE_NUMBER, float64(idx)}, Value{TYPE_STRING, text}); err != nil {
+				return err
+			}
+		}
+		if f.once {
+			f.Close()
+		}
+	case TYPE_PROCESS:
+		p := val.(*Process)
+		for idx := 0; ; idx++ {
+			lineType, text, _ := p.readLine(nil, nil, line)
+			if lineType == TYPE_NULL {
+				break
+			} else if lineType == TYPE_ERROR {
+				return fmt.Errorf("cannot read output of %s: %s at line %d", p.Command, text.(*Error).Message, line)
+			}
+			if err := fn(Value{TYPE_NUMBER, float64(idx)}, Value{TYPE_STRING, text}); err != nil {
+				return err
+			}
+		}
+	case TYPE_WALK:
+		idx := 0
+		return val.(*Walk).each(line, func(path string) error {
+			idx++
+			return fn(Value{TYPE_NUMBER, float64(idx - 1)}, Value{TYPE_STRING, path})
+		})
+	default:
+		return fmt.Errorf("cannot iterate over %s at line %d", typ, line)
+	}
+	return nil
+}
+
+func (i *Interpreter) visitListLiteral(node *ast.ListLiteral) (string, any, error) {
+	list := &List{Elements: make([]Value, len(node.Elements))}
+	for idx, elem := range node.Elements {
+		typ, val, err := i.Interpret(elem)
+		if err != nil {
+			return "", 0, err
+		}
+		list.Elements[idx] = Value{typ, val}
+	}
+	return TYPE_LIST, list, nil
+}
+
+func (i *Interpreter) visitMapLiteral(node *ast.MapLiteral) (string, any, error) {
+	m := NewMap()
+	for idx := range node.Keys {
+		keyType, key, err := i.Interpret(node.Keys[idx])
+		if err != nil {
+			return "", 0, err
+		}
+		valType, val, err := i.Interpret(node.Values[idx])
+		if err != nil {
+			return "", 0, err
+		}
+		if err := m.Set(Value{keyType, key}, Value{valType, val}, node.Line); err != nil {
+			return "", 0, err
+		}
+	}
+	return TYPE_MAP, m, nil
+}
+
+func (i *Interpreter) visitListComp(node *ast.ListComp) (string, any, error) {
+	list := &List{Elements: []Value{}}
+	err := i.comprehend(node.Clause, node.Line, func() error {
+		typ, val, err := i.Interpret(node.Element)
+		if err != nil {
+			return err
+		}
+		list.Elements = append(list.Elements, Value{typ, val})
+		return nil
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	return TYPE_LIST, list, nil
+}
+
+func (i *Interpreter) visitMapComp(node *ast.MapComp) (string, any, error) {
+	m := NewMap()
+	err := i.comprehend(node.Clause, node.Line, func() error {
+		keyType, key, err := i.Interpret(node.Key)
+		if err != nil {
+			return err
+		}
+		valType, val, err := i.Interpret(node.Value)
+		if err != nil {
+			return err
+		}
+		return m.Set(Value{keyType, key}, Value{valType, val}, node.Line)
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	return TYPE_MAP, m, nil
+}
+
+// comprehend runs each once per element of the clause's iterable that passes
+// its filter. The loop variables live in their own scope, so they neither
+// leak into nor overwrite the enclosing block's variables.
+func (i *Interpreter) comprehend(clause ast.CompClause, line int, each func() error) error {
+	iterType, iterVal, err := i.Interpret(clause.Iterable)
+	if err != nil {
+		return err
+	}
+
+	outer := i.env
+	i.env = NewEnclosedEnvironment(outer)
+	defer func() { i.env = outer }()
+
+	return iterate(iterType, iterVal, line, func(key Value, value Value) error {
+		if len(clause.Vars) == 1 {
+			// A single variable takes the element, or the key when iterating a map
+			if iterType == TYPE_MAP {
+				value = key
+			}
+			i.env.Declare(clause.Vars[0], value.Type, value.Value, line)
+		} else {
+			i.env.Declare(clause.Vars[0], key.Type, key.Value, line)
+			i.env.Declare(clause.Vars[1], value.Type, value.Value, line)
+		}
+		if clause.Cond != nil {
+			condType, condVal, err := i.Interpret(clause.Cond)
+			if err != nil {
+				return err
+			}
+			if !isTruthy(condType, condVal) {
+				return nil
+			}
+		}
+		return each()
+	})
+}
@@ -0,0 +1,141 @@
+package interpreter
+
+import (
+	"fmt"
+	"inky/ast"
+)
+
+// EnumType is the runtime value of an enum declaration. Its variants are
+// reachable as fields, e.g. Shape.Circle.
+type EnumType struct {
+	Name     string
+	Variants []*Variant
+}
+
+func (e *EnumType) String() string {
+	return fmt.Sprintf("<enum %s>", e.Name)
+}
+
+// Variant is one alternative of an enum. A variant with payload fields is
+// a constructor; a variant without fields is used directly as a value.
+type Variant struct {
+	Enum   *EnumType
+	Name   string
+	Fields []string
+}
+
+func (v *Variant) String() string {
+	return fmt.Sprintf("<variant %s.%s>", v.Enum.Name, v.Name)
+}
+
+func (v *Variant) fieldIndex(name string) int {
+	for idx, field := range v.Fields {
+		if field == name {
+			return idx
+		}
+	}
+	return -1
+}
+
+// EnumValue is an immutable instance of a variant and its payload.
+type EnumValue struct {
+	Variant *Variant
+	Payload []Value
+}
+
+func (e *EnumValue) String() string {
+	return formatValue(TYPE_ENUM, e)
+}
+
+// Get returns a payload field by name.
+func (e *EnumValue) Get(field string, line int) (string, any, error) {
+	idx := e.Variant.fieldIndex(field)
+	if idx < 0 {
+		return "", 0, fmt.Errorf("variant %s has no field %q at line %d", e.Variant.Name, field, line)
+	}
+	return e.Payload[idx].Type, e.Payload[idx].Value, nil
+}
+
+// variantValue is what a variant's name evaluates to: its constructor, or
+// the value itself when it carries no payload.
+func variantValue(variant *Variant) (string, any) {
+	if len(variant.Fields) == 0 {
+		return TYPE_ENUM, &EnumValue{Variant: variant}
+	}
+	return TYPE_VARIANT, variant
+}
+
+func (i *Interpreter) visitEnumDecl(node *ast.EnumDecl) (string, any, error) {
+	enum := &EnumType{Name: node.Name}
+	if err := i.env.Set(node.Name, TYPE_ENUM_TYPE, enum, node.Line); err != nil {
+		return "", 0, err
+	}
+	for _, decl := range node.Variants {
+		variant := &Variant{Enum: enum, Name: decl.Name, Fields: decl.Fields}
+		enum.Variants = append(enum.Variants, variant)
+		typ, val := variantValue(variant)
+		if err := i.env.Set(decl.Name, typ, val, node.Line); err != nil {
+			return "", 0, err
+		}
+	}
+	return "", 0, nil
+}
+
+// resolveVariant finds the variant a match case refers to.
+func (i *Interpreter) resolveVariant(name string, line int) (*Variant, error) {
+	typ, val, err := i.env.Get(name, line)
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case TYPE_VARIANT:
+		return val.(*Variant), nil
+	case TYPE_ENUM:
+		return val.(*EnumValue).Variant, nil
+	default:
+		return nil, fmt.Errorf("%s is not an enum variant at line %d", name, line)
+	}
+}
+
+// visitMatch runs the first case naming the subject's variant, binding its
+// payload to the case's names in the current scope.
+func (i *Interpreter) visitMatch(node *ast.MatchStmt) (string, any, error) {
+	subjectType, subject, err := i.Interpret(node.Subject)
+	if err != nil {
+		return "", 0, err
+	}
+	if subjectType != TYPE_ENUM {
+		return "", 0, fmt.Errorf("cannot match on %s at line %d", subjectType, node.Line)
+	}
+	value := subject.(*EnumValue)
+
+	for _, c := range node.Cases {
+		variant, err := i.resolveVariant(c.Variant, c.Line)
+		if err != nil {
+			return "", 0, err
+		}
+		if variant != value.Variant {
+			continue
+		}
+		if len(c.Bindings) != len(variant.Fields) {
+			return "", 0, fmt.Errorf("case %s binds %d values, but the variant has %d at line %d", c.Variant, len(c.Bindings), len(variant.Fields), c.Line)
+		}
+		for idx, name := range c.Bindings {
+			if err := i.env.Set(name, value.Payload[idx].Type, value.Payload[idx].Value, c.Line); err != nil {
+				return "", 0, err
+			}
+		}
+		if _, _, err := i.Interpret(c.Stmts); err != nil {
+			return "", 0, err
+		}
+		return "", 0, nil
+	}
+
+	if node.ElseStmts == nil {
+		return "", 0, fmt.Errorf("no case matches %s at line %d", value.Variant.Name, node.Line)
+	}
+	if _, _, err := i.Interpret(node.ElseStmts); err != nil {
+		return "", 0, err
+	}
+	return "", 0, nil
+}
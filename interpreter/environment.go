@@ -0,0 +1,114 @@
+package interpreter
+
+import "fmt"
+
+// binding is a single named value in an Environment.
+type binding struct {
+	typ      string
+	value    any
+	constant bool
+	line     int // line where the binding was declared
+}
+
+// Environment maps variable names to their runtime values. Nested scopes,
+// such as the loop variables of a comprehension, point at their parent.
+type Environment struct {
+	vars   map[string]*binding
+	parent *Environment
+}
+
+func NewEnvironment() *Environment {
+	return &Environment{vars: map[string]*binding{}}
+}
+
+// NewEnclosedEnvironment creates a scope whose lookups fall back to parent.
+func NewEnclosedEnvironment(parent *Environment) *Environment {
+	return &Environment{vars: map[string]*binding{}, parent: parent}
+}
+
+// lookup finds the binding for name in this scope or an enclosing one.
+func (e *Environment) lookup(name string) (*binding, bool) {
+	for env := e; env != nil; env = env.parent {
+		if b, ok := env.vars[name]; ok {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+// Get returns the type and value bound to name.
+func (e *Environment) Get(name string, line int) (string, any, error) {
+	b, ok := e.lookup(name)
+	if !ok {
+		return "", 0, fmt.Errorf("undefined variable %q at line %d", name, line)
+	}
+	return b.typ, b.value, nil
+}
+
+// Set binds name to a value. An existing binding in this or an enclosing
+// scope is updated; otherwise a new one is created in this scope.
+// Assigning to a constant is an error that points back to its declaration.
+func (e *Environment) Set(name string, typ string, value any, line int) error {
+	if b, ok := e.lookup(name); ok {
+		if b.constant {
+			return fmt.Errorf("cannot reassign constant %q declared at line %d, at line %d", name, b.line, line)
+		}
+		b.typ, b.value = typ, value
+		return nil
+	}
+	e.vars[name] = &binding{typ: typ, value: value, line: line}
+	return nil
+}
+
+// Declare binds name in this scope only, shadowing any enclosing binding.
+func (e *Environment) Declare(name string, typ string, value any, line int) {
+	e.vars[name] = &binding{typ: typ, value: value, line: line}
+}
+
+// Define creates a constant binding. Redeclaring an existing constant is an error.
+func (e *Environment) Define(name string, typ string, value any, line int) error {
+	if b, ok := e.lookup(name); ok && b.constant {
+		return fmt.Errorf("cannot reassign constant %q declared at line %d, at line %d", name, b.line, line)
+	}
+	freeze(typ, value)
+	e.vars[name] = &binding{typ: typ, value: value, constant: true, line: line}
+	return nil
+}
+
+// freeze makes a value bound to a constant deeply immutable by marking
+// every list, map and record reachable from it.
+func freeze(typ string, value any) {
+	switch typ {
+	case TYPE_LIST:
+		list := value.(*List)
+		if list.frozen {
+			return
+		}
+		list.frozen = true
+		for _, elem := range list.Elements {
+			freeze(elem.Type, elem.Value)
+		}
+	case TYPE_MAP:
+		m := value.(*Map)
+		if m.frozen {
+			return
+		}
+		m.frozen = true
+		for _, val := range m.values {
+			freeze(val.Type, val.Value)
+		}
+	case TYPE_RECORD:
+		r := value.(*Record)
+		if r.frozen {
+			return
+		}
+		r.frozen = true
+		for idx, val := range r.Values {
+			freeze(r.Types[idx], val)
+		}
+	case TYPE_ENUM:
+		for _, val := range value.(*EnumValue).Payload {
+			freeze(val.Type, val.Value)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package interpreter
+
+import "fmt"
+
+// Error is the value library functions return when an operation outside
+// the interpreter fails, e.g. a missing file. Unlike runtime errors it
+// does not stop the script: errors are falsy, so a script can check the
+// result and read err.message.
+//
+//	text := io.read_file("notes.txt")
+//	if text then println text else println text.message end
+type Error struct {
+	Message string
+}
+
+func (e *Error) String() string {
+	return fmt.Sprintf("<error %s>", e.Message)
+}
+
+// Get returns a field of the error. The only field is message.
+func (e *Error) Get(field string, line int) (string, any, error) {
+	if field != "message" {
+		return "", 0, fmt.Errorf("error has no field %q at line %d", field, line)
+	}
+	return TYPE_STRING, e.Message, nil
+}
+
+// errorValue returns err as a script value.
+func errorValue(err error) (string, any, error) {
+	return TYPE_ERROR, &Error{Message: err.Error()}, nil
+}
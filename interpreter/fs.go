@@ -0,0 +1,233 @@
+package interpreter
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// fileInfoType is the record type returned by fs.stat. modified is in
+// seconds since the Unix epoch.
+var fileInfoType = &RecordType{Name: "FileInfo", Fields: []string{"name", "size", "is_dir", "mode", "modified"}}
+
+// The fs module. Like io, every failure is returned as an error value.
+func init() {
+	RegisterModule(NewModule("fs", []*Builtin{
+		{Name: "list", Params: []string{TYPE_STRING}, Result: TYPE_LIST, Fn: fsList},
+		{Name: "walk", Params: []string{TYPE_STRING}, Result: TYPE_WALK, Fn: fsWalk},
+		{Name: "glob", Params: []string{TYPE_STRING}, Result: TYPE_LIST, Fn: fsGlob},
+		{Name: "stat", Params: []string{TYPE_STRING}, Result: TYPE_RECORD, Fn: fsStat},
+		{Name: "mkdir_all", Params: []string{TYPE_STRING}, Result: TYPE_NULL, Fn: fsMkdirAll},
+		{Name: "remove", Params: []string{TYPE_STRING, TYPE_BOOL}, Optional: 1, Result: TYPE_NULL, Fn: fsRemove},
+		{Name: "rename", Params: []string{TYPE_STRING, TYPE_STRING}, Result: TYPE_NULL, Fn: fsRename},
+		{Name: "temp_dir", Params: []string{TYPE_STRING}, Optional: 1, Result: TYPE_STRING, Fn: fsTempDir},
+	}, nil))
+}
+
+func stringList(strs []string) *List {
+	list := &List{Elements: make([]Value, len(strs))}
+	for idx, str := range strs {
+		list.Elements[idx] = Value{TYPE_STRING, str}
+	}
+	return list
+}
+
+// list(dir) returns the names of the entries in dir, sorted.
+func fsList(i *Interpreter, args []Value, line int) (string, any, error) {
+	entries, err := os.ReadDir(args[0].Value.(string))
+	if err != nil {
+		return errorValue(err)
+	}
+	names := make([]string, len(entries))
+	for idx, entry := range entries {
+		names[idx] = entry.Name()
+	}
+	return TYPE_LIST, stringList(names), nil
+}
+
+// Walk is the value of fs.walk. Iterating over it walks the tree below
+// Root afresh, reading each directory only when the walk reaches it.
+type Walk struct {
+	Root string
+}
+
+func (w *Walk) String() string {
+	return fmt.Sprintf("<walk %s>", w.Root)
+}
+
+// each calls fn with the path of every file and directory below the root,
+// each directory before its contents. An error from fn stops the walk and
+// is returned as is.
+func (w *Walk) each(line int, fn func(path string) error) error {
+	var fnErr error
+	err := filepath.WalkDir(w.Root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == w.Root {
+			return nil
+		}
+		if fnErr = fn(path); fnErr != nil {
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		return fmt.Errorf("cannot walk %s: %v at line %d", w.Root, err, line)
+	}
+	return nil
+}
+
+// walk(dir) lazily yields the path of every file and directory below dir,
+// for iterating with a comprehension. A missing dir is an error value.
+func fsWalk(i *Interpreter, args []Value, line int) (string, any, error) {
+	root := args[0].Value.(string)
+	if _, err := os.Stat(root); err != nil {
+		return errorValue(err)
+	}
+	return TYPE_WALK, &Walk{Root: root}, nil
+}
+
+// glob(pattern) returns the sorted paths matching pattern. Besides the
+// wildcards of a single path segment (*, ? and [...]), a segment of **
+// matches any number of directories, so "**/*.log" finds log files at any
+// depth below the working directory.
+func fsGlob(i *Interpreter, args []Value, line int) (string, any, error) {
+	pattern := filepath.ToSlash(args[0].Value.(string))
+	segments := strings.Split(pattern, "/")
+	root := ""
+	if strings.HasPrefix(pattern, "/") {
+		root, segments = "/", segments[1:]
+	}
+
+	found := map[string]bool{}
+	if err := globFrom(root, segments, found); err != nil {
+		return errorValue(err)
+	}
+	paths := []string{}
+	for path := range found {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	return TYPE_LIST, stringList(paths), nil
+}
+
+// globFrom adds to found the paths below dir that match segments.
+func globFrom(dir string, segments []string, found map[string]bool) error {
+	if len(segments) == 0 {
+		if dir != "" {
+			found[dir] = true
+		}
+		return nil
+	}
+	segment, rest := segments[0], segments[1:]
+	if segment == "" || segment == "." {
+		return globFrom(dir, rest, found)
+	}
+	if segment == ".." {
+		return globFrom(filepath.Join(dir, ".."), rest, found)
+	}
+
+	// Unreadable or missing directories simply have no matches
+	readDir := dir
+	if readDir == "" {
+		readDir = "."
+	}
+	entries, _ := os.ReadDir(readDir)
+
+	if segment == "**" {
+		if err := globFrom(dir, rest, found); err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				if err := globFrom(filepath.Join(dir, entry.Name()), segments, found); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	for _, entry := range entries {
+		ok, err := filepath.Match(segment, entry.Name())
+		if err != nil {
+			return err
+		}
+		if ok {
+			if err := globFrom(filepath.Join(dir, entry.Name()), rest, found); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// stat(path) describes a file as a FileInfo record.
+func fsStat(i *Interpreter, args []Value, line int) (string, any, error) {
+	info, err := os.Stat(args[0].Value.(string))
+	if err != nil {
+		return errorValue(err)
+	}
+	return TYPE_RECORD, &Record{
+		Type:  fileInfoType,
+		Types: []string{TYPE_STRING, TYPE_NUMBER, TYPE_BOOL, TYPE_STRING, TYPE_NUMBER},
+		Values: []any{
+			info.Name(),
+			float64(info.Size()),
+			info.IsDir(),
+			info.Mode().String(),
+			float64(info.ModTime().UnixNano()) / 1e9,
+		},
+	}, nil
+}
+
+// mkdir_all(path) creates a directory and any missing parents.
+func fsMkdirAll(i *Interpreter, args []Value, line int) (string, any, error) {
+	if err := os.MkdirAll(args[0].Value.(string), 0o755); err != nil {
+		return errorValue(err)
+	}
+	return TYPE_NULL, nullValue, nil
+}
+
+// remove(path, recursive) removes a file or an empty directory, or with
+// recursive set, a directory and everything in it.
+func fsRemove(i *Interpreter, args []Value, line int) (string, any, error) {
+	path := args[0].Value.(string)
+	var err error
+	if len(args) == 2 && args[1].Value.(bool) {
+		err = os.RemoveAll(path)
+	} else {
+		err = os.Remove(path)
+	}
+	if err != nil {
+		return errorValue(err)
+	}
+	return TYPE_NULL, nullValue, nil
+}
+
+func fsRename(i *Interpreter, args []Value, line int) (string, any, error) {
+	if err := os.Rename(args[0].Value.(string), args[1].Value.(string)); err != nil {
+		return errorValue(err)
+	}
+	return TYPE_NULL, nullValue, nil
+}
+
+// temp_dir(prefix) creates a new, empty temporary directory and returns
+// its path. The script is responsible for removing it.
+func fsTempDir(i *Interpreter, args []Value, line int) (string, any, error) {
+	prefix := "inky"
+	if len(args) == 1 {
+		prefix = args[0].Value.(string)
+	}
+	dir, err := os.MkdirTemp("", prefix)
+	if err != nil {
+		return errorValue(err)
+	}
+	return TYPE_STRING, dir, nil
+}
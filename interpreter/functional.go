@@ -0,0 +1,374 @@
+package interpreter
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// Higher-order collection builtins. Each takes the collection first, so
+// they read naturally in pipelines: xs |> filter(bool) |> map(str). The
+// collection may be anything a comprehension can iterate; as there, a
+// map contributes its keys. Functions are called through CallValue.
+func init() {
+	for _, builtin := range []*Builtin{
+		{Name: "map", Params: []string{TYPE_ANY, TYPE_ANY}, Result: TYPE_LIST, Fn: builtinMap},
+		{Name: "filter", Params: []string{TYPE_ANY, TYPE_ANY}, Result: TYPE_LIST, Fn: builtinFilter},
+		{Name: "reduce", Params: []string{TYPE_ANY, TYPE_ANY, TYPE_ANY}, Optional: 1, Result: TYPE_ANY, Fn: builtinReduce},
+		{Name: "any", Params: []string{TYPE_ANY, TYPE_ANY}, Optional: 1, Result: TYPE_BOOL, Fn: builtinAny},
+		{Name: "all", Params: []string{TYPE_ANY, TYPE_ANY}, Optional: 1, Result: TYPE_BOOL, Fn: builtinAll},
+		{Name: "zip", Params: []string{TYPE_ANY}, Variadic: true, Result: TYPE_LIST, Fn: builtinZip},
+		{Name: "enumerate", Params: []string{TYPE_ANY, TYPE_NUMBER}, Optional: 1, Result: TYPE_LIST, Fn: builtinEnumerate},
+		{Name: "reverse", Params: []string{TYPE_ANY}, Result: TYPE_ANY, Fn: builtinReverse},
+		{Name: "unique", Params: []string{TYPE_ANY}, Result: TYPE_LIST, Fn: builtinUnique},
+		{Name: "group_by", Params: []string{TYPE_ANY, TYPE_ANY}, Result: TYPE_MAP, Fn: builtinGroupBy},
+		{Name: "sort", Params: []string{TYPE_ANY, TYPE_MAP}, Optional: 1, Result: TYPE_LIST, Fn: builtinSort},
+	} {
+		RegisterBuiltin(builtin)
+	}
+}
+
+// elements collects the values a comprehension over val would bind.
+func elements(typ string, val any, line int) ([]Value, error) {
+	if typ == TYPE_LIST {
+		return val.(*List).Elements, nil
+	}
+	result := []Value{}
+	err := iterate(typ, val, line, func(key Value, value Value) error {
+		if typ == TYPE_MAP {
+			value = key
+		}
+		result = append(result, value)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// callable checks that a function argument can be called.
+func callable(fn string, arg Value, line int) error {
+	if !isCallable(arg.Type) {
+		return fmt.Errorf("%s expects a function, got %s at line %d", fn, arg.Type, line)
+	}
+	return nil
+}
+
+// collectionAndFunction unpacks the usual (collection, function) arguments.
+func collectionAndFunction(fn string, args []Value, line int) ([]Value, error) {
+	elems, err := elements(args[0].Type, args[0].Value, line)
+	if err != nil {
+		return nil, err
+	}
+	return elems, callable(fn, args[1], line)
+}
+
+// map(xs, f) is the list of f(x) for each x in xs.
+func builtinMap(i *Interpreter, args []Value, line int) (string, any, error) {
+	elems, err := collectionAndFunction("map", args, line)
+	if err != nil {
+		return "", 0, err
+	}
+	result := &List{Elements: make([]Value, len(elems))}
+	for idx, elem := range elems {
+		typ, val, err := i.CallValue(args[1], []Value{elem}, line)
+		if err != nil {
+			return "", 0, err
+		}
+		result.Elements[idx] = Value{typ, val}
+	}
+	return TYPE_LIST, result, nil
+}
+
+// filter(xs, f) is the list of the x in xs for which f(x) is truthy.
+func builtinFilter(i *Interpreter, args []Value, line int) (string, any, error) {
+	elems, err := collectionAndFunction("filter", args, line)
+	if err != nil {
+		return "", 0, err
+	}
+	result := &List{Elements: []Value{}}
+	for _, elem := range elems {
+		typ, val, err := i.CallValue(args[1], []Value{elem}, line)
+		if err != nil {
+			return "", 0, err
+		}
+		if isTruthy(typ, val) {
+			result.Elements = append(result.Elements, elem)
+		}
+	}
+	return TYPE_LIST, result, nil
+}
+
+// reduce(xs, f, initial) folds xs from the left: f(f(initial, x0), x1)...
+// Without initial, the first element is used and xs must not be empty.
+func builtinReduce(i *Interpreter, args []Value, line int) (string, any, error) {
+	elems, err := collectionAndFunction("reduce", args, line)
+	if err != nil {
+		return "", 0, err
+	}
+	var acc Value
+	if len(args) == 3 {
+		acc = args[2]
+	} else if len(elems) == 0 {
+		return "", 0, fmt.Errorf("reduce of an empty collection needs an initial value at line %d", line)
+	} else {
+		acc, elems = elems[0], elems[1:]
+	}
+	for _, elem := range elems {
+		acc.Type, acc.Value, err = i.CallValue(args[1], []Value{acc, elem}, line)
+		if err != nil {
+			return "", 0, err
+		}
+	}
+	return acc.Type, acc.Value, nil
+}
+
+// anyTruthy tests elements, or f(element) if f is given, for truthiness
+// until one gives want, reporting whether one did.
+func anyTruthy(i *Interpreter, fn string, args []Value, line int, want bool) (bool, error) {
+	elems, err := elements(args[0].Type, args[0].Value, line)
+	if err != nil {
+		return false, err
+	}
+	if len(args) == 2 {
+		if err := callable(fn, args[1], line); err != nil {
+			return false, err
+		}
+	}
+	for _, elem := range elems {
+		if len(args) == 2 {
+			if elem.Type, elem.Value, err = i.CallValue(args[1], []Value{elem}, line); err != nil {
+				return false, err
+			}
+		}
+		if isTruthy(elem.Type, elem.Value) == want {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// any(xs, f) reports whether some x in xs, or f(x), is truthy.
+func builtinAny(i *Interpreter, args []Value, line int) (string, any, error) {
+	found, err := anyTruthy(i, "any", args, line, true)
+	if err != nil {
+		return "", 0, err
+	}
+	return TYPE_BOOL, found, nil
+}
+
+// all(xs, f) reports whether every x in xs, or f(x), is truthy.
+func builtinAll(i *Interpreter, args []Value, line int) (string, any, error) {
+	found, err := anyTruthy(i, "all", args, line, false)
+	if err != nil {
+		return "", 0, err
+	}
+	return TYPE_BOOL, !found, nil
+}
+
+// zip(xs, ys, ...) pairs up elements at the same position, as a list of
+// lists, stopping at the end of the shortest collection.
+func builtinZip(i *Interpreter, args []Value, line int) (string, any, error) {
+	columns := make([][]Value, len(args))
+	length := -1
+	for idx, arg := range args {
+		elems, err := elements(arg.Type, arg.Value, line)
+		if err != nil {
+			return "", 0, err
+		}
+		columns[idx] = elems
+		if length < 0 || len(elems) < length {
+			length = len(elems)
+		}
+	}
+	result := &List{Elements: make([]Value, length)}
+	for row := range length {
+		tuple := &List{Elements: make([]Value, len(columns))}
+		for col := range columns {
+			tuple.Elements[col] = columns[col][row]
+		}
+		result.Elements[row] = Value{TYPE_LIST, tuple}
+	}
+	return TYPE_LIST, result, nil
+}
+
+// enumerate(xs, start) pairs each element with its position, counting
+// from start or 0, as a list of [position, element] lists.
+func builtinEnumerate(i *Interpreter, args []Value, line int) (string, any, error) {
+	elems, err := elements(args[0].Type, args[0].Value, line)
+	if err != nil {
+		return "", 0, err
+	}
+	start := 0.0
+	if len(args) == 2 {
+		start = args[1].Value.(float64)
+	}
+	result := &List{Elements: make([]Value, len(elems))}
+	for idx, elem := range elems {
+		pair := &List{Elements: []Value{{TYPE_NUMBER, start + float64(idx)}, elem}}
+		result.Elements[idx] = Value{TYPE_LIST, pair}
+	}
+	return TYPE_LIST, result, nil
+}
+
+// reverse(xs) returns a reversed copy of a string, or of any other
+// collection as a list.
+func builtinReverse(i *Interpreter, args []Value, line int) (string, any, error) {
+	if args[0].Type == TYPE_STRING {
+		runes := []rune(args[0].Value.(string))
+		slices.Reverse(runes)
+		return TYPE_STRING, string(runes), nil
+	}
+	elems, err := elements(args[0].Type, args[0].Value, line)
+	if err != nil {
+		return "", 0, err
+	}
+	result := &List{Elements: slices.Clone(elems)}
+	slices.Reverse(result.Elements)
+	return TYPE_LIST, result, nil
+}
+
+// unique(xs) is the list of the elements of xs without repeats, each kept
+// at its first position. Elements are compared like ==.
+func builtinUnique(i *Interpreter, args []Value, line int) (string, any, error) {
+	elems, err := elements(args[0].Type, args[0].Value, line)
+	if err != nil {
+		return "", 0, err
+	}
+	result := &List{Elements: []Value{}}
+	seen := NewMap() // hashable elements seen so far, for a fast check
+	for _, elem := range elems {
+		if isHashable(elem.Type) {
+			if _, ok := seen.Get(elem); ok {
+				continue
+			}
+			seen.Set(elem, elem, line)
+		} else if slices.ContainsFunc(result.Elements, func(other Value) bool { return equal(elem, other) }) {
+			continue
+		}
+		result.Elements = append(result.Elements, elem)
+	}
+	return TYPE_LIST, result, nil
+}
+
+// group_by(xs, f) maps each distinct f(x) to the list of the x that gave
+// it, in order of first appearance.
+func builtinGroupBy(i *Interpreter, args []Value, line int) (string, any, error) {
+	elems, err := collectionAndFunction("group_by", args, line)
+	if err != nil {
+		return "", 0, err
+	}
+	groups := NewMap()
+	for _, elem := range elems {
+		keyType, key, err := i.CallValue(args[1], []Value{elem}, line)
+		if err != nil {
+			return "", 0, err
+		}
+		group, ok := groups.Get(Value{keyType, key})
+		if !ok {
+			group = Value{TYPE_LIST, &List{Elements: []Value{}}}
+			if err := groups.Set(Value{keyType, key}, group, line); err != nil {
+				return "", 0, err
+			}
+		}
+		list := group.Value.(*List)
+		list.Elements = append(list.Elements, elem)
+	}
+	return TYPE_MAP, groups, nil
+}
+
+// sort(xs, options) returns the elements of xs as a sorted list. The sort
+// is stable. Numbers and strings are ordered naturally; options may give
+// a key function to sort by key(x), a compare function where compare(a, b)
+// is negative, zero or positive as a sorts before, with or after b, and
+// reverse to sort in descending order.
+func builtinSort(i *Interpreter, args []Value, line int) (string, any, error) {
+	elems, err := elements(args[0].Type, args[0].Value, line)
+	if err != nil {
+		return "", 0, err
+	}
+	var key, compare *Value
+	reverse := false
+	if len(args) == 2 {
+		options := args[1].Value.(*Map)
+		for idx, name := range options.keys {
+			val := options.values[idx]
+			switch name.Value {
+			case "key", "compare":
+				if err := callable("sort", val, line); err != nil {
+					return "", 0, err
+				}
+				if name.Value == "key" {
+					key = &val
+				} else {
+					compare = &val
+				}
+			case "reverse":
+				reverse = isTruthy(val.Type, val.Value)
+			default:
+				return "", 0, fmt.Errorf("sort has no option %s at line %d", name, line)
+			}
+		}
+		if key != nil && compare != nil {
+			return "", 0, fmt.Errorf("sort takes a key or a compare function, not both, at line %d", line)
+		}
+	}
+
+	// Sort positions so that keys are computed once per element
+	keys := slices.Clone(elems)
+	if key != nil {
+		for idx, elem := range elems {
+			if keys[idx].Type, keys[idx].Value, err = i.CallValue(*key, []Value{elem}, line); err != nil {
+				return "", 0, err
+			}
+		}
+	}
+	order := make([]int, len(elems))
+	for idx := range order {
+		order[idx] = idx
+	}
+	var sortErr error
+	slices.SortStableFunc(order, func(a, b int) int {
+		if sortErr != nil {
+			return 0
+		}
+		var result int
+		if compare != nil {
+			typ, val, err := i.CallValue(*compare, []Value{keys[a], keys[b]}, line)
+			if err != nil {
+				sortErr = err
+			} else if typ != TYPE_NUMBER {
+				sortErr = fmt.Errorf("sort compare function must return a number, got %s at line %d", typ, line)
+			} else {
+				result = cmp.Compare(val.(float64), 0)
+			}
+		} else {
+			result, sortErr = compareValues(keys[a], keys[b], line)
+		}
+		if reverse {
+			return -result
+		}
+		return result
+	})
+	if sortErr != nil {
+		return "", 0, sortErr
+	}
+
+	result := &List{Elements: make([]Value, len(order))}
+	for idx, pos := range order {
+		result.Elements[idx] = elems[pos]
+	}
+	return TYPE_LIST, result, nil
+}
+
+// compareValues orders two numbers or two strings.
+func compareValues(a Value, b Value, line int) (int, error) {
+	if a.Type == TYPE_NUMBER && b.Type == TYPE_NUMBER {
+		return cmp.Compare(a.Value.(float64), b.Value.(float64)), nil
+	}
+	if a.Type == TYPE_STRING && b.Type == TYPE_STRING {
+		return cmp.Compare(a.Value.(string), b.Value.(string)), nil
+	}
+	return 0, fmt.Errorf("cannot compare %s and %s at line %d", a.Type, b.Type, line)
+}
@@ -1,24 +1,92 @@
 package interpreter
 
 import (
+	"bufio"
 	"fmt"
 	"inky/ast"
+	"inky/macro"
 	"inky/token"
 	"inky/utils"
+	"io"
 	"math"
+	"os"
+	"regexp"
 )
 
 // Constants for different runtime value types
 const (
-	TYPE_NUMBER = "TYPE_NUMBER"
-	TYPE_STRING = "TYPE_STRING"
-	TYPE_BOOL   = "TYPE_BOOL"
+	TYPE_NUMBER      = "TYPE_NUMBER"
+	TYPE_STRING      = "TYPE_STRING"
+	TYPE_BOOL        = "TYPE_BOOL"
+	TYPE_NULL        = "TYPE_NULL"
+	TYPE_RECORD_TYPE = "TYPE_RECORD_TYPE"
+	TYPE_RECORD      = "TYPE_RECORD"
+	TYPE_RANGE       = "TYPE_RANGE"
+	TYPE_LIST        = "TYPE_LIST"
+	TYPE_MAP         = "TYPE_MAP"
+	TYPE_ENUM_TYPE   = "TYPE_ENUM_TYPE"
+	TYPE_VARIANT     = "TYPE_VARIANT"
+	TYPE_ENUM        = "TYPE_ENUM"
+	TYPE_BUILTIN     = "TYPE_BUILTIN"
+	TYPE_AST         = "TYPE_AST"
+	TYPE_MODULE      = "TYPE_MODULE"
+	TYPE_ERROR       = "TYPE_ERROR"
+	TYPE_FILE        = "TYPE_FILE"
+	TYPE_PROCESS     = "TYPE_PROCESS"
+	TYPE_REGEX       = "TYPE_REGEX"
+	TYPE_WALK        = "TYPE_WALK"
 )
 
-type Interpreter struct{}
+// null is the runtime value of the null literal.
+type null struct{}
+
+func (null) String() string {
+	return "null"
+}
+
+var nullValue = null{}
+
+type Interpreter struct {
+	env *Environment
+
+	// Stdin is read by io.read_line and io.lines(). A host that also reads
+	// standard input, like the REPL, can share its reader so that neither
+	// loses input buffered by the other.
+	Stdin *bufio.Reader
+
+	// Expander expands the macros in code run by eval. A host that expands
+	// its own source should use it too, so that eval sees the macros the
+	// script declared.
+	Expander *macro.Expander
+
+	// ProcPolicy, if set, is asked before the script starts any process
+	// through the proc module. Returning an error denies the call, so an
+	// embedder can restrict or disable subprocesses.
+	ProcPolicy func(cmd string, args []string) error
+
+	handles []io.Closer               // files and processes opened by the script, closed by Close
+	regexps map[string]*regexp.Regexp // compiled patterns by source, see the re module
+}
 
 func NewInterpreter() *Interpreter {
-	return &Interpreter{}
+	interpreter := &Interpreter{
+		env:      NewEnvironment(),
+		Stdin:    bufio.NewReader(os.Stdin),
+		Expander: macro.NewExpander(),
+		regexps:  map[string]*regexp.Regexp{},
+	}
+	interpreter.defineBuiltins()
+	interpreter.SetArgs(nil)
+	return interpreter
+}
+
+// Close releases the files the script left open and kills the processes
+// it left running. Hosts call it when the script ends.
+func (i *Interpreter) Close() {
+	for _, handle := range i.handles {
+		handle.Close()
+	}
+	i.handles = nil
 }
 
 func (i *Interpreter) Interpret(node ast.Node) (string, any, error) {
@@ -39,11 +107,63 @@ func (i *Interpreter) Interpret(node ast.Node) (string, any, error) {
 		return TYPE_STRING, string(node.Value), nil
 	case *ast.Bool:
 		return TYPE_BOOL, node.Value, nil
+	case *ast.Null:
+		return TYPE_NULL, nullValue, nil
+	case *ast.Identifier:
+		return i.env.Get(node.Name, node.Line)
 	case *ast.Stmts:
+		// A block evaluates to the value of its last statement
+		var typ string
+		var val any = 0
 		for _, stmt := range node.Stmts {
-			i.Interpret(stmt)
+			var err error
+			typ, val, err = i.Interpret(stmt)
+			if err != nil {
+				return "", 0, err
+			}
+		}
+		return typ, val, nil
+	case *ast.AssignStmt:
+		return i.visitAssign(node)
+	case *ast.ConstStmt:
+		typ, val, err := i.Interpret(node.Right)
+		if err != nil {
+			return "", 0, err
+		}
+		if err := i.env.Define(node.Name.Name, typ, val, node.Line); err != nil {
+			return "", 0, err
+		}
+		return "", 0, nil
+	case *ast.RecordDecl:
+		recordType := &RecordType{Name: node.Name, Fields: node.Fields}
+		if err := i.env.Set(node.Name, TYPE_RECORD_TYPE, recordType, node.Line); err != nil {
+			return "", 0, err
 		}
 		return "", 0, nil
+	case *ast.Call:
+		return i.visitCall(node)
+	case *ast.Index:
+		return i.visitIndex(node)
+	case *ast.Slice:
+		return i.visitSlice(node)
+	case *ast.Range:
+		return i.visitRange(node)
+	case *ast.ListLiteral:
+		return i.visitListLiteral(node)
+	case *ast.MapLiteral:
+		return i.visitMapLiteral(node)
+	case *ast.ListComp:
+		return i.visitListComp(node)
+	case *ast.MapComp:
+		return i.visitMapComp(node)
+	case *ast.FieldAccess:
+		return i.visitFieldAccess(node)
+	case *ast.EnumDecl:
+		return i.visitEnumDecl(node)
+	case *ast.MatchStmt:
+		return i.visitMatch(node)
+	case *ast.MacroDecl:
+		return "", 0, fmt.Errorf("macro %s must be expanded before it is interpreted, at line %d", node.Name, node.Line)
 	case *ast.PrintStmt:
 		_, exprVal, err := i.Interpret(node.Value)
 		if err != nil {
@@ -56,13 +176,14 @@ func (i *Interpreter) Interpret(node ast.Node) (string, any, error) {
 		if err != nil {
 			return "", 0, err
 		}
-		if condType != TYPE_BOOL {
-			return "", 0, fmt.Errorf("expected boolean expression, got %s at line %d", condType, node.Line)
-		}
-		if condVal.(bool) {
-			i.Interpret(node.ThenStmts)
+		if isTruthy(condType, condVal) {
+			if _, _, err := i.Interpret(node.ThenStmts); err != nil {
+				return "", 0, err
+			}
 		} else if node.ElseStmts != nil {
-			i.Interpret(node.ElseStmts)
+			if _, _, err := i.Interpret(node.ElseStmts); err != nil {
+				return "", 0, err
+			}
 		}
 		return "", 0, nil
 	default:
@@ -70,6 +191,141 @@ func (i *Interpreter) Interpret(node ast.Node) (string, any, error) {
 	}
 }
 
+func (i *Interpreter) visitAssign(node *ast.AssignStmt) (string, any, error) {
+	switch left := node.Left.(type) {
+	case *ast.Identifier:
+		typ, val, err := i.Interpret(node.Right)
+		if err != nil {
+			return "", 0, err
+		}
+		if err := i.env.Set(left.Name, typ, val, left.Line); err != nil {
+			return "", 0, err
+		}
+	case *ast.FieldAccess:
+		record, err := i.evalRecord(left)
+		if err != nil {
+			return "", 0, err
+		}
+		typ, val, err := i.Interpret(node.Right)
+		if err != nil {
+			return "", 0, err
+		}
+		if err := record.Set(left.Field, typ, val, left.Line); err != nil {
+			return "", 0, err
+		}
+	case *ast.Index:
+		typ, val, err := i.Interpret(node.Right)
+		if err != nil {
+			return "", 0, err
+		}
+		if err := i.assignIndex(left, Value{typ, val}); err != nil {
+			return "", 0, err
+		}
+	default:
+		return "", 0, fmt.Errorf("cannot assign to %s at line %d", node.Left, node.Line)
+	}
+	return "", 0, nil
+}
+
+func (i *Interpreter) visitFieldAccess(node *ast.FieldAccess) (string, any, error) {
+	typ, val, err := i.Interpret(node.Object)
+	if err != nil {
+		return "", 0, err
+	}
+	switch typ {
+	case TYPE_RECORD:
+		return val.(*Record).Get(node.Field, node.Line)
+	case TYPE_ENUM:
+		return val.(*EnumValue).Get(node.Field, node.Line)
+	case TYPE_AST:
+		return val.(*AST).Get(node.Field, node.Line)
+	case TYPE_MODULE:
+		return val.(*Module).Get(node.Field, node.Line)
+	case TYPE_ERROR:
+		return val.(*Error).Get(node.Field, node.Line)
+	case TYPE_FILE:
+		return val.(*File).Get(node.Field, node.Line)
+	case TYPE_PROCESS:
+		return val.(*Process).Get(node.Field, node.Line)
+	case TYPE_REGEX:
+		return val.(*Regex).Get(node.Field, node.Line)
+	case TYPE_ENUM_TYPE:
+		enum := val.(*EnumType)
+		for _, variant := range enum.Variants {
+			if variant.Name == node.Field {
+				typ, val := variantValue(variant)
+				return typ, val, nil
+			}
+		}
+		return "", 0, fmt.Errorf("enum %s has no variant %q at line %d", enum.Name, node.Field, node.Line)
+	default:
+		return "", 0, fmt.Errorf("cannot access field %q on %s at line %d", node.Field, typ, node.Line)
+	}
+}
+
+// evalRecord evaluates the object of a field assignment, which must be a record.
+func (i *Interpreter) evalRecord(node *ast.FieldAccess) (*Record, error) {
+	typ, val, err := i.Interpret(node.Object)
+	if err != nil {
+		return nil, err
+	}
+	if typ != TYPE_RECORD {
+		return nil, fmt.Errorf("cannot access field %q on %s at line %d", node.Field, typ, node.Line)
+	}
+	return val.(*Record), nil
+}
+
+func (i *Interpreter) visitCall(node *ast.Call) (string, any, error) {
+	calleeType, callee, err := i.Interpret(node.Callee)
+	if err != nil {
+		return "", 0, err
+	}
+	args := make([]Value, len(node.Args))
+	for idx, arg := range node.Args {
+		args[idx].Type, args[idx].Value, err = i.Interpret(arg)
+		if err != nil {
+			return "", 0, err
+		}
+	}
+	return i.CallValue(Value{calleeType, callee}, args, node.Line)
+}
+
+// CallValue applies a callable value (a builtin, record type or variant)
+// to evaluated arguments, as if the script had called it at line. It is
+// re-entrant: builtins such as map use it to call back into script values
+// while they are themselves being called, and the caller's scope is
+// restored afterwards even if the call fails.
+func (i *Interpreter) CallValue(callee Value, args []Value, line int) (string, any, error) {
+	defer func(env *Environment) { i.env = env }(i.env)
+	switch callee.Type {
+	case TYPE_RECORD_TYPE:
+		recordType := callee.Value.(*RecordType)
+		if len(args) != len(recordType.Fields) {
+			return "", 0, fmt.Errorf("record %s expects %d fields, got %d at line %d", recordType.Name, len(recordType.Fields), len(args), line)
+		}
+		record := &Record{Type: recordType, Types: make([]string, len(args)), Values: make([]any, len(args))}
+		for idx, arg := range args {
+			record.Types[idx], record.Values[idx] = arg.Type, arg.Value
+		}
+		return TYPE_RECORD, record, nil
+	case TYPE_BUILTIN:
+		return callee.Value.(*Builtin).Call(i, args, line)
+	case TYPE_VARIANT:
+		variant := callee.Value.(*Variant)
+		if len(args) != len(variant.Fields) {
+			return "", 0, fmt.Errorf("variant %s expects %d values, got %d at line %d", variant.Name, len(variant.Fields), len(args), line)
+		}
+		return TYPE_ENUM, &EnumValue{Variant: variant, Payload: append([]Value{}, args...)}, nil
+	default:
+		return "", 0, fmt.Errorf("cannot call value of type %s at line %d", callee.Type, line)
+	}
+}
+
+// isCallable reports whether values of type typ can be called.
+func isCallable(typ string) bool {
+	return typ == TYPE_BUILTIN || typ == TYPE_RECORD_TYPE || typ == TYPE_VARIANT
+}
+
 func (i *Interpreter) visitBinOp(node *ast.BinOp) (string, any, error) {
 	leftType, leftVal, err := i.Interpret(node.Left)
 	if err != nil {
@@ -87,14 +343,31 @@ func (i *Interpreter) visitBinOp(node *ast.BinOp) (string, any, error) {
 			leftNum := leftVal.(float64)
 			rightNum := rightVal.(float64)
 			return TYPE_NUMBER, leftNum + rightNum, nil
-		} else if leftType == TYPE_STRING || rightType == TYPE_STRING {
-			leftStr := fmt.Sprintf("%v", leftVal)
-			rightStr := fmt.Sprintf("%v", rightVal)
+		} else if leftType == TYPE_STRING && rightType == TYPE_STRING {
+			leftStr := leftVal.(string)
+			rightStr := rightVal.(string)
 			return TYPE_STRING, leftStr + rightStr, nil
+		} else if leftType == TYPE_LIST && rightType == TYPE_LIST {
+			elements := append([]Value{}, leftVal.(*List).Elements...)
+			elements = append(elements, rightVal.(*List).Elements...)
+			return TYPE_LIST, &List{Elements: elements}, nil
 		} else {
 			utils.RuntimeError(fmt.Sprintf("unsupported operator %v between %v and %v", node.Op.Lexeme, leftType, rightType), node.Op.Line)
 		}
 
+	case token.TOK_CONCAT:
+		// '++' converts both operands to strings, unlike '+'
+		leftStr := fmt.Sprintf("%v", leftVal)
+		rightStr := fmt.Sprintf("%v", rightVal)
+		return TYPE_STRING, leftStr + rightStr, nil
+
+	case token.TOK_IN:
+		found, err := contains(leftType, leftVal, rightType, rightVal, node.Op.Line)
+		if err != nil {
+			return "", 0, err
+		}
+		return TYPE_BOOL, found, nil
+
 	case token.TOK_MINUS:
 		if leftType == TYPE_NUMBER && rightType == TYPE_NUMBER {
 			leftNum := leftVal.(float64)
@@ -196,7 +469,10 @@ func (i *Interpreter) visitBinOp(node *ast.BinOp) (string, any, error) {
 		}
 
 	case token.TOK_EQEQ:
-		if leftType == TYPE_NUMBER && rightType == TYPE_NUMBER {
+		if leftType == TYPE_NULL || rightType == TYPE_NULL {
+			// null only equals itself, and may be compared with any value
+			return TYPE_BOOL, leftType == rightType, nil
+		} else if leftType == TYPE_NUMBER && rightType == TYPE_NUMBER {
 			leftNum := leftVal.(float64)
 			rightNum := rightVal.(float64)
 			return TYPE_BOOL, leftNum == rightNum, nil
@@ -208,12 +484,17 @@ func (i *Interpreter) visitBinOp(node *ast.BinOp) (string, any, error) {
 			leftBool := leftVal.(bool)
 			rightBool := rightVal.(bool)
 			return TYPE_BOOL, leftBool == rightBool, nil
+		} else if leftType == rightType {
+			return TYPE_BOOL, equal(Value{leftType, leftVal}, Value{rightType, rightVal}), nil
 		} else {
 			utils.RuntimeError(fmt.Sprintf("unsupported operator %v between %v and %v", node.Op.Type, leftType, rightType), node.Op.Line)
 		}
 
 	case token.TOK_NE:
-		if leftType == TYPE_NUMBER && rightType == TYPE_NUMBER {
+		if leftType == TYPE_NULL || rightType == TYPE_NULL {
+			// null only equals itself, and may be compared with any value
+			return TYPE_BOOL, leftType != rightType, nil
+		} else if leftType == TYPE_NUMBER && rightType == TYPE_NUMBER {
 			leftNum := leftVal.(float64)
 			rightNum := rightVal.(float64)
 			return TYPE_BOOL, leftNum != rightNum, nil
@@ -225,6 +506,8 @@ func (i *Interpreter) visitBinOp(node *ast.BinOp) (string, any, error) {
 			leftBool := leftVal.(bool)
 			rightBool := rightVal.(bool)
 			return TYPE_BOOL, leftBool != rightBool, nil
+		} else if leftType == rightType {
+			return TYPE_BOOL, !equal(Value{leftType, leftVal}, Value{rightType, rightVal}), nil
 		} else {
 			utils.RuntimeError(fmt.Sprintf("unsupported operator %v between %v and %v", node.Op.Type, leftType, rightType), node.Op.Line)
 		}
@@ -260,21 +543,42 @@ func (i *Interpreter) visitUnOp(node *ast.UnOp) (string, any, error) {
 		}
 
 	case token.TOK_NOT:
-		if operandType == TYPE_BOOL {
-			operandBool := operand.(bool)
-			return operandType, !operandBool, nil
-		} else if operandType == TYPE_NUMBER {
-			operandNum := operand.(float64)
-			return TYPE_NUMBER, -operandNum, nil
-		} else {
-			return "", 0, fmt.Errorf("unsupported unary operator %v on type %v", node.Op.Type, operandType)
-		}
+		// '~' is logical not for every value, numbers included; '-' negates numbers
+		return TYPE_BOOL, !isTruthy(operandType, operand), nil
 
 	default:
 		return "", 0, fmt.Errorf("unsupported unary operator %v", node.Op.Type)
 	}
 }
 
+// isTruthy defines which values count as true wherever a condition is
+// expected: in if statements, loops, logical not, and, and or.
+// null, errors, false, 0, NaN, the empty string and empty lists and maps are falsy.
+// Every other value, including every record, is truthy.
+func isTruthy(typ string, val any) bool {
+	switch typ {
+	case TYPE_NULL, TYPE_ERROR:
+		return false
+	case TYPE_BOOL:
+		return val.(bool)
+	case TYPE_NUMBER:
+		num := val.(float64)
+		return num != 0 && !math.IsNaN(num)
+	case TYPE_STRING:
+		return val.(string) != ""
+	case TYPE_LIST:
+		return len(val.(*List).Elements) > 0
+	case TYPE_MAP:
+		return val.(*Map).Len() > 0
+	default:
+		return true
+	}
+}
+
+// visitLogical evaluates 'and' and 'or' with short circuiting. Like Lua,
+// the result is one of the operands rather than a bool: 'a and b' is a if
+// a is falsy and b otherwise, 'a or b' is a if a is truthy and b otherwise.
+// This makes 'x or default' a defaulting idiom.
 func (i *Interpreter) visitLogical(node *ast.LogicalOp) (string, any, error) {
 	leftType, leftVal, err := i.Interpret(node.Left)
 	if err != nil {
@@ -283,17 +587,15 @@ func (i *Interpreter) visitLogical(node *ast.LogicalOp) (string, any, error) {
 
 	// short circuit evaluation
 	if node.Op.Type == token.TOK_AND {
-		if leftType == TYPE_BOOL && leftVal.(bool) {
-			return i.Interpret(node.Right)
-		} else {
-			return TYPE_BOOL, false, nil
+		if !isTruthy(leftType, leftVal) {
+			return leftType, leftVal, nil
 		}
+		return i.Interpret(node.Right)
 	} else if node.Op.Type == token.TOK_OR {
-		if leftType == TYPE_BOOL && leftVal.(bool) {
-			return TYPE_BOOL, true, nil
-		} else {
-			return i.Interpret(node.Right)
+		if isTruthy(leftType, leftVal) {
+			return leftType, leftVal, nil
 		}
+		return i.Interpret(node.Right)
 	}
-	return "", 0, nil
+	return "", 0, fmt.Errorf("unsupported logical operator %v", node.Op.Type)
 }
@@ -0,0 +1,211 @@
+package interpreter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// File is an open file handle. Its methods are read with dot access:
+// f.read(), f.read_line(), f.write(s) and f.close(). Iterating over a
+// file yields its remaining lines.
+type File struct {
+	Path   string
+	file   *os.File
+	reader *bufio.Reader
+	closed bool
+	std    bool // a standard stream, which close leaves open
+	once   bool // closed by iterating to its end, as io.lines files are
+}
+
+func (f *File) String() string {
+	return fmt.Sprintf("<file %s>", f.Path)
+}
+
+// Close closes the handle. Closing a closed handle does nothing.
+func (f *File) Close() error {
+	if f.closed || f.std {
+		return nil
+	}
+	f.closed = true
+	return f.file.Close()
+}
+
+// Get returns one of the handle's methods, or its path.
+func (f *File) Get(field string, line int) (string, any, error) {
+	var method *Builtin
+	switch field {
+	case "path":
+		return TYPE_STRING, f.Path, nil
+	case "read":
+		method = &Builtin{Params: []string{}, Result: TYPE_STRING, Fn: f.read}
+	case "read_line":
+		method = &Builtin{Params: []string{}, Result: TYPE_STRING, Fn: f.readLine}
+	case "write":
+		method = &Builtin{Params: []string{TYPE_STRING}, Result: TYPE_NULL, Fn: f.write}
+	case "close":
+		method = &Builtin{Params: []string{}, Result: TYPE_NULL, Fn: f.close}
+	default:
+		return "", 0, fmt.Errorf("file has no field %q at line %d", field, line)
+	}
+	method.Name = "file." + field
+	return TYPE_BUILTIN, method, nil
+}
+
+func (f *File) checkOpen() error {
+	if f.closed {
+		return fmt.Errorf("file %s is closed", f.Path)
+	}
+	return nil
+}
+
+func (f *File) checkReadable() error {
+	if f.reader == nil {
+		return fmt.Errorf("file %s is not readable", f.Path)
+	}
+	return f.checkOpen()
+}
+
+// read returns the rest of the file.
+func (f *File) read(i *Interpreter, args []Value, line int) (string, any, error) {
+	if err := f.checkReadable(); err != nil {
+		return errorValue(err)
+	}
+	data, err := io.ReadAll(f.reader)
+	if err != nil {
+		return errorValue(err)
+	}
+	return TYPE_STRING, string(data), nil
+}
+
+// readLine returns the next line without its line ending, or null at the
+// end of the file.
+func (f *File) readLine(i *Interpreter, args []Value, line int) (string, any, error) {
+	if err := f.checkReadable(); err != nil {
+		return errorValue(err)
+	}
+	return readLine(f.reader)
+}
+
+func (f *File) write(i *Interpreter, args []Value, line int) (string, any, error) {
+	if err := f.checkOpen(); err != nil {
+		return errorValue(err)
+	}
+	if _, err := f.file.WriteString(args[0].Value.(string)); err != nil {
+		return errorValue(err)
+	}
+	return TYPE_NULL, nullValue, nil
+}
+
+func (f *File) close(i *Interpreter, args []Value, line int) (string, any, error) {
+	if err := f.Close(); err != nil {
+		return errorValue(err)
+	}
+	return TYPE_NULL, nullValue, nil
+}
+
+func readLine(reader *bufio.Reader) (string, any, error) {
+	text, err := reader.ReadString('\n')
+	if err == io.EOF && text == "" {
+		return TYPE_NULL, nullValue, nil
+	} else if err != nil && err != io.EOF {
+		return errorValue(err)
+	}
+	text = strings.TrimSuffix(text, "\n")
+	return TYPE_STRING, strings.TrimSuffix(text, "\r"), nil
+}
+
+// The io module. Failures such as a missing file are returned as error
+// values rather than stopping the script; see Error.
+func init() {
+	RegisterModule(NewModule("io", []*Builtin{
+		{Name: "open", Params: []string{TYPE_STRING, TYPE_STRING}, Optional: 1, Result: TYPE_FILE, Fn: ioOpen},
+		{Name: "read_file", Params: []string{TYPE_STRING}, Result: TYPE_STRING, Fn: ioReadFile},
+		{Name: "write_file", Params: []string{TYPE_STRING, TYPE_STRING}, Result: TYPE_NULL, Fn: ioWriteFile},
+		{Name: "append_file", Params: []string{TYPE_STRING, TYPE_STRING}, Result: TYPE_NULL, Fn: ioAppendFile},
+		{Name: "lines", Params: []string{TYPE_STRING}, Optional: 1, Result: TYPE_FILE, Fn: ioLines},
+		{Name: "read_line", Params: []string{}, Result: TYPE_STRING, Fn: ioReadLine},
+	}, map[string]Value{
+		"stderr": {TYPE_FILE, &File{Path: "<stderr>", file: os.Stderr, std: true}},
+	}))
+}
+
+// open(path, mode) opens a file for reading ("r", the default), writing
+// ("w", truncating it) or appending ("a"). Handles still open when the
+// script ends are closed by Interpreter.Close.
+func ioOpen(i *Interpreter, args []Value, line int) (string, any, error) {
+	mode := "r"
+	if len(args) == 2 {
+		mode = args[1].Value.(string)
+	}
+	flags := map[string]int{
+		"r": os.O_RDONLY,
+		"w": os.O_WRONLY | os.O_CREATE | os.O_TRUNC,
+		"a": os.O_WRONLY | os.O_CREATE | os.O_APPEND,
+	}
+	flag, ok := flags[mode]
+	if !ok {
+		return "", 0, fmt.Errorf("io.open mode must be \"r\", \"w\" or \"a\", got %q at line %d", mode, line)
+	}
+
+	path := args[0].Value.(string)
+	file, err := os.OpenFile(path, flag, 0o644)
+	if err != nil {
+		return errorValue(err)
+	}
+	handle := &File{Path: path, file: file, reader: bufio.NewReader(file)}
+	i.handles = append(i.handles, handle)
+	return TYPE_FILE, handle, nil
+}
+
+func ioReadFile(i *Interpreter, args []Value, line int) (string, any, error) {
+	data, err := os.ReadFile(args[0].Value.(string))
+	if err != nil {
+		return errorValue(err)
+	}
+	return TYPE_STRING, string(data), nil
+}
+
+func ioWriteFile(i *Interpreter, args []Value, line int) (string, any, error) {
+	if err := os.WriteFile(args[0].Value.(string), []byte(args[1].Value.(string)), 0o644); err != nil {
+		return errorValue(err)
+	}
+	return TYPE_NULL, nullValue, nil
+}
+
+func ioAppendFile(i *Interpreter, args []Value, line int) (string, any, error) {
+	file, err := os.OpenFile(args[0].Value.(string), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return errorValue(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(args[1].Value.(string)); err != nil {
+		return errorValue(err)
+	}
+	return TYPE_NULL, nullValue, nil
+}
+
+// lines(path) opens a file to iterate over its lines, without their line
+// endings, with a comprehension. Lines are read as the iteration asks for
+// them, and the file closes itself at its end. Without a path, lines reads
+// standard input.
+func ioLines(i *Interpreter, args []Value, line int) (string, any, error) {
+	if len(args) == 0 {
+		return TYPE_FILE, &File{Path: "<stdin>", reader: i.Stdin, std: true}, nil
+	}
+	path := args[0].Value.(string)
+	file, err := os.Open(path)
+	if err != nil {
+		return errorValue(err)
+	}
+	handle := &File{Path: path, file: file, reader: bufio.NewReader(file), once: true}
+	i.handles = append(i.handles, handle)
+	return TYPE_FILE, handle, nil
+}
+
+// read_line() reads a line from standard input, or returns null at its end.
+func ioReadLine(i *Interpreter, args []Value, line int) (string, any, error) {
+	return readLine(i.Stdin)
+}
@@ -0,0 +1,222 @@
+package interpreter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// The json module. Objects decode to maps that keep the document's key
+// order, and maps encode in their insertion order, so output is
+// deterministic. Malformed input decodes to an error value; a value that
+// cannot be encoded is a runtime error.
+func init() {
+	RegisterModule(NewModule("json", []*Builtin{
+		{Name: "decode", Params: []string{TYPE_STRING}, Result: TYPE_ANY, Fn: jsonDecode},
+		{Name: "encode", Params: []string{TYPE_ANY, TYPE_MAP}, Optional: 1, Result: TYPE_STRING, Fn: jsonEncode},
+	}, nil))
+}
+
+func jsonDecode(i *Interpreter, args []Value, line int) (string, any, error) {
+	dec := json.NewDecoder(strings.NewReader(args[0].Value.(string)))
+	val, err := decodeJSON(dec)
+	if err == nil {
+		// Nothing but whitespace may follow the value
+		if _, err = dec.Token(); err == io.EOF {
+			return val.Type, val.Value, nil
+		} else if err == nil {
+			err = errors.New("unexpected data after the JSON value")
+		}
+	}
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return errorValue(fmt.Errorf("invalid JSON: %w", err))
+}
+
+// decodeJSON reads one value from dec token by token, which keeps the
+// order of object keys.
+func decodeJSON(dec *json.Decoder) (Value, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return Value{}, err
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '[' {
+			list := &List{Elements: []Value{}}
+			for dec.More() {
+				elem, err := decodeJSON(dec)
+				if err != nil {
+					return Value{}, err
+				}
+				list.Elements = append(list.Elements, elem)
+			}
+			_, err := dec.Token()
+			return Value{TYPE_LIST, list}, err
+		}
+		m := NewMap()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return Value{}, err
+			}
+			val, err := decodeJSON(dec)
+			if err != nil {
+				return Value{}, err
+			}
+			m.Set(Value{TYPE_STRING, key.(string)}, val, 0)
+		}
+		_, err := dec.Token()
+		return Value{TYPE_MAP, m}, err
+	case string:
+		return Value{TYPE_STRING, tok}, nil
+	case float64:
+		return Value{TYPE_NUMBER, tok}, nil
+	case bool:
+		return Value{TYPE_BOOL, tok}, nil
+	default:
+		return Value{TYPE_NULL, nullValue}, nil
+	}
+}
+
+const maxJSONIndent = 16
+
+// encode(value, options) returns value as JSON text. Setting indent in
+// the options map to a number of spaces, up to maxJSONIndent, spreads the
+// output over lines.
+func jsonEncode(i *Interpreter, args []Value, line int) (string, any, error) {
+	indent := 0
+	if len(args) == 2 {
+		options := args[1].Value.(*Map)
+		for idx, key := range options.keys {
+			if key.Value != "indent" {
+				return "", 0, fmt.Errorf("json.encode has no option %s at line %d", key, line)
+			}
+			if options.values[idx].Type != TYPE_NUMBER {
+				return "", 0, fmt.Errorf("json.encode option \"indent\" must be a number, got %s at line %d", options.values[idx].Type, line)
+			}
+			var err error
+			if indent, err = intArg("json.encode", "indent", options.values[idx], line); err != nil {
+				return "", 0, err
+			}
+			if indent < 0 || indent > maxJSONIndent {
+				return "", 0, fmt.Errorf("json.encode option \"indent\" must be between 0 and %d, got %d at line %d", maxJSONIndent, indent, line)
+			}
+		}
+	}
+
+	enc := &jsonEncoder{line: line, active: map[any]bool{}}
+	if err := enc.encode(args[0]); err != nil {
+		return "", 0, err
+	}
+	if indent == 0 {
+		return TYPE_STRING, enc.buf.String(), nil
+	}
+	var out bytes.Buffer
+	json.Indent(&out, enc.buf.Bytes(), "", strings.Repeat(" ", indent))
+	return TYPE_STRING, out.String(), nil
+}
+
+// jsonEncoder writes compact JSON. active holds the lists, maps and
+// records being encoded, to detect values that contain themselves.
+type jsonEncoder struct {
+	buf    bytes.Buffer
+	line   int
+	active map[any]bool
+}
+
+func (e *jsonEncoder) encode(val Value) error {
+	switch val.Type {
+	case TYPE_NULL:
+		e.buf.WriteString("null")
+	case TYPE_BOOL:
+		fmt.Fprint(&e.buf, val.Value)
+	case TYPE_NUMBER:
+		num := val.Value.(float64)
+		if math.IsNaN(num) || math.IsInf(num, 0) {
+			return fmt.Errorf("json.encode cannot encode %v at line %d", num, e.line)
+		}
+		data, _ := json.Marshal(num)
+		e.buf.Write(data)
+	case TYPE_STRING:
+		e.str(val.Value.(string))
+	case TYPE_LIST, TYPE_MAP, TYPE_RECORD:
+		if e.active[val.Value] {
+			return fmt.Errorf("json.encode cannot encode a value that contains itself at line %d", e.line)
+		}
+		e.active[val.Value] = true
+		defer delete(e.active, val.Value)
+		return e.container(val)
+	default:
+		return fmt.Errorf("json.encode cannot encode %s at line %d", val.Type, e.line)
+	}
+	return nil
+}
+
+// container encodes a list as an array, and a map or record as an object.
+// Map keys that are not strings are written as strings, e.g. 1 as "1",
+// which is an error if another key of the map is written the same.
+func (e *jsonEncoder) container(val Value) error {
+	var keys []string
+	var values []Value
+	switch val.Type {
+	case TYPE_LIST:
+		e.buf.WriteByte('[')
+		for idx, elem := range val.Value.(*List).Elements {
+			if idx > 0 {
+				e.buf.WriteByte(',')
+			}
+			if err := e.encode(elem); err != nil {
+				return err
+			}
+		}
+		e.buf.WriteByte(']')
+		return nil
+	case TYPE_MAP:
+		m := val.Value.(*Map)
+		seen := map[string]Value{} // JSON key -> map key written as it
+		for idx, key := range m.keys {
+			name := fmt.Sprint(key.Value)
+			if other, ok := seen[name]; ok {
+				return fmt.Errorf("json.encode cannot encode both %s and %s as the key %q at line %d", other, key, name, e.line)
+			}
+			seen[name] = key
+			keys = append(keys, name)
+			values = append(values, m.values[idx])
+		}
+	case TYPE_RECORD:
+		record := val.Value.(*Record)
+		keys = record.Type.Fields
+		for idx := range record.Values {
+			values = append(values, Value{record.Types[idx], record.Values[idx]})
+		}
+	}
+
+	e.buf.WriteByte('{')
+	for idx, key := range keys {
+		if idx > 0 {
+			e.buf.WriteByte(',')
+		}
+		e.str(key)
+		e.buf.WriteByte(':')
+		if err := e.encode(values[idx]); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteByte('}')
+	return nil
+}
+
+// str writes a JSON string without escaping <, > and &, which need no
+// escaping outside HTML.
+func (e *jsonEncoder) str(s string) {
+	enc := json.NewEncoder(&e.buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	e.buf.Truncate(e.buf.Len() - 1) // Encode appends a newline
+}
@@ -0,0 +1,132 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+)
+
+// The math module. There is no separate integer type yet, so functions
+// such as floor and round return whole numbers of the one number type.
+// Arguments outside a function's domain, like sqrt(-1), are runtime
+// errors rather than NaN.
+func init() {
+	RegisterModule(NewModule("math", []*Builtin{
+		unaryMath("sqrt", math.Sqrt, func(x float64) bool { return x >= 0 }),
+		unaryMath("sin", math.Sin, nil),
+		unaryMath("cos", math.Cos, nil),
+		unaryMath("tan", math.Tan, nil),
+		unaryMath("asin", math.Asin, func(x float64) bool { return x >= -1 && x <= 1 }),
+		unaryMath("acos", math.Acos, func(x float64) bool { return x >= -1 && x <= 1 }),
+		unaryMath("atan", math.Atan, nil),
+		unaryMath("exp", math.Exp, nil),
+		unaryMath("floor", math.Floor, nil),
+		unaryMath("ceil", math.Ceil, nil),
+		unaryMath("abs", math.Abs, nil),
+		{Name: "atan2", Params: []string{TYPE_NUMBER, TYPE_NUMBER}, Result: TYPE_NUMBER, Fn: mathAtan2},
+		{Name: "log", Params: []string{TYPE_NUMBER, TYPE_NUMBER}, Optional: 1, Result: TYPE_NUMBER, Fn: mathLog},
+		{Name: "round", Params: []string{TYPE_NUMBER, TYPE_NUMBER}, Optional: 1, Result: TYPE_NUMBER, Fn: mathRound},
+		{Name: "min", Params: []string{TYPE_NUMBER}, Variadic: true, Result: TYPE_NUMBER, Fn: mathMin},
+		{Name: "max", Params: []string{TYPE_NUMBER}, Variadic: true, Result: TYPE_NUMBER, Fn: mathMax},
+		{Name: "clamp", Params: []string{TYPE_NUMBER, TYPE_NUMBER, TYPE_NUMBER}, Result: TYPE_NUMBER, Fn: mathClamp},
+		{Name: "is_nan", Params: []string{TYPE_NUMBER}, Result: TYPE_BOOL, Fn: mathIsNaN},
+		{Name: "is_inf", Params: []string{TYPE_NUMBER}, Result: TYPE_BOOL, Fn: mathIsInf},
+	}, map[string]Value{
+		"pi":  {TYPE_NUMBER, math.Pi},
+		"e":   {TYPE_NUMBER, math.E},
+		"inf": {TYPE_NUMBER, math.Inf(1)},
+		"nan": {TYPE_NUMBER, math.NaN()},
+	}))
+}
+
+// unaryMath wraps a one-argument Go function. If domain is given, arguments
+// it rejects are errors.
+func unaryMath(name string, fn func(float64) float64, domain func(float64) bool) *Builtin {
+	return &Builtin{
+		Name:   name,
+		Params: []string{TYPE_NUMBER},
+		Result: TYPE_NUMBER,
+		Fn: func(i *Interpreter, args []Value, line int) (string, any, error) {
+			x := args[0].Value.(float64)
+			if domain != nil && !domain(x) {
+				return "", 0, fmt.Errorf("math.%s is undefined for %v at line %d", name, x, line)
+			}
+			return TYPE_NUMBER, fn(x), nil
+		},
+	}
+}
+
+func mathAtan2(i *Interpreter, args []Value, line int) (string, any, error) {
+	return TYPE_NUMBER, math.Atan2(args[0].Value.(float64), args[1].Value.(float64)), nil
+}
+
+// log(x, base) is the logarithm of x in base, or the natural logarithm
+// when base is omitted.
+func mathLog(i *Interpreter, args []Value, line int) (string, any, error) {
+	x := args[0].Value.(float64)
+	if x <= 0 {
+		return "", 0, fmt.Errorf("math.log is undefined for %v at line %d", x, line)
+	}
+	if len(args) == 1 {
+		return TYPE_NUMBER, math.Log(x), nil
+	}
+	base := args[1].Value.(float64)
+	if base <= 0 || base == 1 {
+		return "", 0, fmt.Errorf("math.log is undefined for base %v at line %d", base, line)
+	}
+	return TYPE_NUMBER, math.Log(x) / math.Log(base), nil
+}
+
+// round(x, digits) rounds half away from zero to the given number of
+// decimal places, or to a whole number when digits is omitted.
+func mathRound(i *Interpreter, args []Value, line int) (string, any, error) {
+	x := args[0].Value.(float64)
+	if len(args) == 1 {
+		return TYPE_NUMBER, math.Round(x), nil
+	}
+	digits, err := intArg("math.round", "number of digits", args[1], line)
+	if err != nil {
+		return "", 0, err
+	}
+	scale := math.Pow(10, float64(digits))
+	if math.IsNaN(x) || math.IsInf(x, 0) || math.IsInf(scale, 0) || math.IsInf(x*scale, 0) {
+		// A float64 has no digits that far right of the point to round away
+		return TYPE_NUMBER, x, nil
+	} else if scale == 0 {
+		// nor any that far left of it to keep
+		return TYPE_NUMBER, math.Copysign(0, x), nil
+	}
+	return TYPE_NUMBER, math.Round(x*scale) / scale, nil
+}
+
+func mathMin(i *Interpreter, args []Value, line int) (string, any, error) {
+	result := args[0].Value.(float64)
+	for _, arg := range args[1:] {
+		result = math.Min(result, arg.Value.(float64))
+	}
+	return TYPE_NUMBER, result, nil
+}
+
+func mathMax(i *Interpreter, args []Value, line int) (string, any, error) {
+	result := args[0].Value.(float64)
+	for _, arg := range args[1:] {
+		result = math.Max(result, arg.Value.(float64))
+	}
+	return TYPE_NUMBER, result, nil
+}
+
+// clamp(x, lo, hi) limits x to the range [lo, hi].
+func mathClamp(i *Interpreter, args []Value, line int) (string, any, error) {
+	x, lo, hi := args[0].Value.(float64), args[1].Value.(float64), args[2].Value.(float64)
+	if lo > hi {
+		return "", 0, fmt.Errorf("math.clamp lower bound %v is greater than upper bound %v at line %d", lo, hi, line)
+	}
+	return TYPE_NUMBER, math.Max(lo, math.Min(x, hi)), nil
+}
+
+func mathIsNaN(i *Interpreter, args []Value, line int) (string, any, error) {
+	return TYPE_BOOL, math.IsNaN(args[0].Value.(float64)), nil
+}
+
+func mathIsInf(i *Interpreter, args []Value, line int) (string, any, error) {
+	return TYPE_BOOL, math.IsInf(args[0].Value.(float64), 0), nil
+}
@@ -0,0 +1,373 @@
+package interpreter
+
+import (
+	"fmt"
+	"inky/ast"
+	"inky/lexer"
+	"inky/parser"
+	"inky/token"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// AST wraps a syntax tree node so scripts can inspect, build and evaluate
+// code. Each exported field of the node is readable as a snake_case field,
+// e.g. n.left or n.then_stmts, and n.kind names the node type.
+type AST struct {
+	Node ast.Node
+}
+
+func (a *AST) String() string {
+	return a.Node.String()
+}
+
+// astKinds lists the node types that ast_node can construct.
+var astKinds = map[string]reflect.Type{}
+
+func init() {
+	for _, node := range []any{
+		ast.Integer{}, ast.Float{}, ast.Bool{}, ast.String{}, ast.Null{},
+		ast.Identifier{}, ast.BinOp{}, ast.UnOp{}, ast.LogicalOp{}, ast.Grouping{},
+		ast.Call{}, ast.FieldAccess{}, ast.Index{}, ast.Slice{}, ast.Range{},
+		ast.ListLiteral{}, ast.MapLiteral{}, ast.ListComp{}, ast.MapComp{}, ast.CompClause{},
+		ast.Stmts{}, ast.PrintStmt{}, ast.IfStmt{}, ast.AssignStmt{}, ast.ConstStmt{},
+		ast.RecordDecl{}, ast.EnumDecl{}, ast.EnumVariant{}, ast.MatchStmt{}, ast.MatchCase{},
+	} {
+		typ := reflect.TypeOf(node)
+		astKinds[typ.Name()] = typ
+	}
+
+	for _, builtin := range []*Builtin{
+		{Name: "eval", Params: []string{TYPE_ANY}, Result: TYPE_ANY, Fn: builtinEval},
+		{Name: "parse", Params: []string{TYPE_STRING}, Result: TYPE_AST, Fn: builtinParse},
+		{Name: "ast_node", Params: []string{TYPE_STRING, TYPE_MAP}, Result: TYPE_AST, Fn: builtinASTNode},
+	} {
+		RegisterBuiltin(builtin)
+	}
+}
+
+// Kind returns the node's type name, e.g. "BinOp".
+func (a *AST) Kind() string {
+	return reflect.TypeOf(a.Node).Elem().Name()
+}
+
+// Get returns a field of the node converted to a script value.
+func (a *AST) Get(field string, line int) (string, any, error) {
+	if field == "kind" {
+		return TYPE_STRING, a.Kind(), nil
+	}
+	node := reflect.ValueOf(a.Node).Elem()
+	for idx := 0; idx < node.NumField(); idx++ {
+		if snakeCase(node.Type().Field(idx).Name) == field {
+			val := toScriptValue(node.Field(idx))
+			return val.Type, val.Value, nil
+		}
+	}
+	return "", 0, fmt.Errorf("%s node has no field %q at line %d", a.Kind(), field, line)
+}
+
+// snakeCase converts a Go field name like ThenStmts to then_stmts.
+func snakeCase(name string) string {
+	var sb strings.Builder
+	for idx, ch := range name {
+		if unicode.IsUpper(ch) {
+			if idx > 0 {
+				sb.WriteByte('_')
+			}
+			ch = unicode.ToLower(ch)
+		}
+		sb.WriteRune(ch)
+	}
+	return sb.String()
+}
+
+// toScriptValue converts a field of a node into a script value. Nested
+// nodes become AST values, slices become lists and operator tokens
+// become their lexeme.
+func toScriptValue(field reflect.Value) Value {
+	switch field.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if field.IsNil() {
+			return Value{TYPE_NULL, nullValue}
+		}
+		return Value{TYPE_AST, &AST{Node: field.Interface().(ast.Node)}}
+	case reflect.Struct:
+		if tok, ok := field.Interface().(token.Token); ok {
+			return Value{TYPE_STRING, tok.Lexeme}
+		}
+		// Nested struct values such as ConstStmt.Name are exposed through a copy
+		copied := reflect.New(field.Type())
+		copied.Elem().Set(field)
+		return Value{TYPE_AST, &AST{Node: copied.Interface().(ast.Node)}}
+	case reflect.Slice:
+		list := &List{Elements: make([]Value, field.Len())}
+		for idx := range field.Len() {
+			list.Elements[idx] = toScriptValue(field.Index(idx))
+		}
+		return Value{TYPE_LIST, list}
+	case reflect.String:
+		return Value{TYPE_STRING, field.String()}
+	case reflect.Int:
+		return Value{TYPE_NUMBER, float64(field.Int())}
+	case reflect.Float64:
+		return Value{TYPE_NUMBER, field.Float()}
+	case reflect.Bool:
+		return Value{TYPE_BOOL, field.Bool()}
+	default:
+		return Value{TYPE_NULL, nullValue}
+	}
+}
+
+// fromScriptValue converts a script value into a node field of type typ,
+// the inverse of toScriptValue.
+func fromScriptValue(val Value, typ reflect.Type, line int) (reflect.Value, error) {
+	mismatch := func() (reflect.Value, error) {
+		return reflect.Value{}, fmt.Errorf("expected %s, got %s at line %d", typ, val.Type, line)
+	}
+
+	switch typ.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if val.Type == TYPE_NULL {
+			return reflect.Zero(typ), nil
+		}
+		if val.Type != TYPE_AST || !reflect.TypeOf(val.Value.(*AST).Node).AssignableTo(typ) {
+			return mismatch()
+		}
+		return reflect.ValueOf(val.Value.(*AST).Node), nil
+	case reflect.Struct:
+		if typ == reflect.TypeOf(token.Token{}) {
+			if val.Type != TYPE_STRING {
+				return mismatch()
+			}
+			tokens, err := tokenize(val.Value.(string))
+			if err != nil || len(tokens) != 1 {
+				return reflect.Value{}, fmt.Errorf("%q is not a single operator at line %d", val.Value, line)
+			}
+			return reflect.ValueOf(tokens[0]), nil
+		}
+		if val.Type != TYPE_AST || reflect.TypeOf(val.Value.(*AST).Node) != reflect.PointerTo(typ) {
+			return mismatch()
+		}
+		return reflect.ValueOf(val.Value.(*AST).Node).Elem(), nil
+	case reflect.Slice:
+		if val.Type != TYPE_LIST {
+			return mismatch()
+		}
+		elements := val.Value.(*List).Elements
+		slice := reflect.MakeSlice(typ, len(elements), len(elements))
+		for idx, elem := range elements {
+			converted, err := fromScriptValue(elem, typ.Elem(), line)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			slice.Index(idx).Set(converted)
+		}
+		return slice, nil
+	case reflect.String:
+		if val.Type != TYPE_STRING {
+			return mismatch()
+		}
+		return reflect.ValueOf(val.Value.(string)), nil
+	case reflect.Int:
+		num, err := toInt(val.Type, val.Value, line)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(num), nil
+	case reflect.Float64:
+		if val.Type != TYPE_NUMBER {
+			return mismatch()
+		}
+		return reflect.ValueOf(val.Value.(float64)), nil
+	case reflect.Bool:
+		if val.Type != TYPE_BOOL {
+			return mismatch()
+		}
+		return reflect.ValueOf(val.Value.(bool)), nil
+	default:
+		return mismatch()
+	}
+}
+
+// syntaxError carries an error out of the lexer or parser, whose error
+// hooks must not return.
+type syntaxError struct {
+	msg  string
+	line int
+}
+
+// catchSyntaxError recovers a syntaxError into *err, and re-raises any
+// other panic.
+func catchSyntaxError(err *error) {
+	if r := recover(); r != nil {
+		syntax, ok := r.(syntaxError)
+		if !ok {
+			panic(r)
+		}
+		*err = fmt.Errorf("syntax error at line %d: %s", syntax.line, strings.TrimPrefix(syntax.msg, "Error: "))
+	}
+}
+
+func raiseSyntaxError(msg string, line int) {
+	panic(syntaxError{msg, line})
+}
+
+// tokenize lexes source, returning any lexing error instead of exiting.
+func tokenize(source string) (tokens []token.Token, err error) {
+	defer catchSyntaxError(&err)
+	lex := lexer.NewLexer([]byte(source))
+	lex.OnError = raiseSyntaxError
+	return lex.Tokenize(), nil
+}
+
+// parseSource lexes and parses source text into a syntax tree, returning
+// any syntax error instead of exiting.
+func parseSource(source string) (node ast.Node, err error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+	defer catchSyntaxError(&err)
+	p := parser.NewParser(tokens)
+	p.OnError = raiseSyntaxError
+	return p.Parse(), nil
+}
+
+// eval(code) runs a source string or an AST value in the current environment
+// and returns the value of its last statement, or null if it has none, as
+// after an assignment. Macros are expanded first. A syntax or macro error
+// gives an error value.
+func builtinEval(i *Interpreter, args []Value, line int) (string, any, error) {
+	var node ast.Node
+	switch args[0].Type {
+	case TYPE_STRING:
+		var err error
+		if node, err = parseSource(args[0].Value.(string)); err != nil {
+			return errorValue(err)
+		}
+	case TYPE_AST:
+		node = args[0].Value.(*AST).Node
+	default:
+		return "", 0, fmt.Errorf("eval expects a string or an AST, got %s at line %d", args[0].Type, line)
+	}
+	node, err := i.Expander.Expand(node)
+	if err != nil {
+		return errorValue(err)
+	}
+	typ, val, err := i.Interpret(node)
+	if err == nil && typ == "" {
+		return TYPE_NULL, nullValue, nil
+	}
+	return typ, val, err
+}
+
+// parse(src) returns the syntax tree of src without running it, or an
+// error value if src has a syntax error.
+func builtinParse(i *Interpreter, args []Value, line int) (string, any, error) {
+	node, err := parseSource(args[0].Value.(string))
+	if err != nil {
+		return errorValue(err)
+	}
+	return TYPE_AST, &AST{Node: node}, nil
+}
+
+// ast_node(kind, fields) constructs a node of the given kind from a map of
+// snake_case field names to values. Omitted blocks are empty, optional
+// children such as an else block are left out, and an omitted line
+// defaults to the line of the call. Any other child must be given.
+func builtinASTNode(i *Interpreter, args []Value, line int) (string, any, error) {
+	kind := args[0].Value.(string)
+	typ, ok := astKinds[kind]
+	if !ok {
+		return "", 0, fmt.Errorf("unknown AST node kind %q at line %d", kind, line)
+	}
+
+	node := reflect.New(typ)
+	if field := node.Elem().FieldByName("Line"); field.IsValid() {
+		field.SetInt(int64(line))
+	}
+	fields := args[1].Value.(*Map)
+	for idx, key := range fields.keys {
+		if key.Type != TYPE_STRING {
+			return "", 0, fmt.Errorf("AST field names must be strings, got %s at line %d", key.Type, line)
+		}
+		name := key.Value.(string)
+		found := false
+		for f := 0; f < typ.NumField(); f++ {
+			if snakeCase(typ.Field(f).Name) != name {
+				continue
+			}
+			val, err := fromScriptValue(fields.values[idx], typ.Field(f).Type, line)
+			if err != nil {
+				return "", 0, fmt.Errorf("%s.%s: %w", kind, name, err)
+			}
+			node.Elem().Field(f).Set(val)
+			found = true
+		}
+		if !found {
+			return "", 0, fmt.Errorf("%s node has no field %q at line %d", kind, name, line)
+		}
+	}
+	if err := fillChildren(node.Elem(), line); err != nil {
+		return "", 0, err
+	}
+	return TYPE_AST, &AST{Node: node.Interface().(ast.Node)}, nil
+}
+
+// optionalChildren lists the node fields that may be null, as the parser
+// leaves them when the source omits them.
+var optionalChildren = map[string]bool{
+	"Slice.Start": true, "Slice.Stop": true, "Slice.Step": true,
+	"CompClause.Cond": true, "IfStmt.ElseStmts": true, "MatchStmt.ElseStmts": true,
+}
+
+var (
+	stmtsType = reflect.TypeOf(&ast.Stmts{})
+	tokenType = reflect.TypeOf(token.Token{})
+)
+
+// fillChildren checks that the node built by ast_node has every child the
+// interpreter relies on, giving each omitted block an empty one.
+func fillChildren(node reflect.Value, line int) error {
+	typ := node.Type()
+	for f := range typ.NumField() {
+		field := node.Field(f)
+		name := snakeCase(typ.Field(f).Name)
+		missing := func() error {
+			return fmt.Errorf("%s node needs field %q at line %d", typ.Name(), name, line)
+		}
+		switch field.Kind() {
+		case reflect.Interface, reflect.Pointer:
+			if !field.IsNil() || optionalChildren[typ.Name()+"."+typ.Field(f).Name] {
+				continue
+			}
+			if field.Type() != stmtsType {
+				return missing()
+			}
+			field.Set(reflect.ValueOf(&ast.Stmts{Stmts: []ast.Stmt{}, Line: line}))
+		case reflect.Struct:
+			if field.IsZero() {
+				return missing()
+			}
+			if field.Type() != tokenType {
+				if err := fillChildren(field, line); err != nil {
+					return err
+				}
+			}
+		case reflect.Slice:
+			for idx := range field.Len() {
+				if elem := field.Index(idx); elem.Kind() == reflect.Interface && elem.IsNil() {
+					return fmt.Errorf("%s node field %q cannot contain null at line %d", typ.Name(), name, line)
+				}
+			}
+		}
+	}
+	if m, ok := node.Addr().Interface().(*ast.MapLiteral); ok && len(m.Keys) != len(m.Values) {
+		return fmt.Errorf("MapLiteral node needs as many keys as values, got %d and %d at line %d", len(m.Keys), len(m.Values), line)
+	}
+	if c, ok := node.Addr().Interface().(*ast.CompClause); ok && (len(c.Vars) < 1 || len(c.Vars) > 2) {
+		return fmt.Errorf("CompClause node needs 1 or 2 vars, got %d at line %d", len(c.Vars), line)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+)
+
+// Module is a named library of builtins and constants, such as strings,
+// whose members are read with dot access: strings.upper("a").
+type Module struct {
+	Name    string
+	Members map[string]Value
+}
+
+// NewModule builds a module from its functions and constants. Functions
+// are given their qualified name, e.g. "strings.upper", for error messages.
+func NewModule(name string, functions []*Builtin, constants map[string]Value) *Module {
+	module := &Module{Name: name, Members: map[string]Value{}}
+	for member, val := range constants {
+		module.Members[member] = val
+	}
+	for _, fn := range functions {
+		module.Members[fn.Name] = Value{TYPE_BUILTIN, fn}
+		fn.Name = name + "." + fn.Name
+	}
+	return module
+}
+
+func (m *Module) String() string {
+	return fmt.Sprintf("<module %s>", m.Name)
+}
+
+// Get returns the member called field.
+func (m *Module) Get(field string, line int) (string, any, error) {
+	val, ok := m.Members[field]
+	if !ok {
+		return "", 0, fmt.Errorf("module %s has no member %q at line %d", m.Name, field, line)
+	}
+	return val.Type, val.Value, nil
+}
+
+// modules is the registry of library modules bound in every new interpreter.
+var modules = map[string]*Module{}
+
+// RegisterModule adds a module to the registry. Like RegisterBuiltin, it
+// must be called before the interpreters that should see it are created.
+func RegisterModule(module *Module) {
+	if _, ok := modules[module.Name]; ok {
+		panic(fmt.Sprintf("module %s registered twice", module.Name))
+	}
+	modules[module.Name] = module
+}
+
+// intArg converts a number argument of a builtin that only makes sense
+// for whole numbers, such as a repeat count. Integers beyond 2^53 are
+// rejected, as a float64 cannot count exactly past it.
+func intArg(fn string, what string, val Value, line int) (int, error) {
+	num := val.Value.(float64)
+	if num != math.Trunc(num) || math.IsInf(num, 0) {
+		return 0, fmt.Errorf("%s expects an integer %s, got %v at line %d", fn, what, num, line)
+	}
+	if math.Abs(num) > 1<<53 {
+		return 0, fmt.Errorf("%s %s %v is out of range at line %d", fn, what, num, line)
+	}
+	return int(num), nil
+}
@@ -0,0 +1,92 @@
+package interpreter
+
+import (
+	"fmt"
+	"os"
+)
+
+// ExitError is returned by Interpret when the script calls os.exit. It
+// unwinds the script like any error; the host should then close the
+// interpreter and exit with Code.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit with status %d", e.Code)
+}
+
+// The os module. os.args is set per interpreter by SetArgs.
+func init() {
+	RegisterModule(NewModule("os", []*Builtin{
+		{Name: "getenv", Params: []string{TYPE_STRING, TYPE_ANY}, Optional: 1, Result: TYPE_STRING, Fn: osGetenv},
+		{Name: "setenv", Params: []string{TYPE_STRING, TYPE_STRING}, Result: TYPE_NULL, Fn: osSetenv},
+		{Name: "exit", Params: []string{TYPE_NUMBER}, Optional: 1, Result: TYPE_NULL, Fn: osExit},
+		{Name: "cwd", Params: []string{}, Result: TYPE_STRING, Fn: osCwd},
+		{Name: "hostname", Params: []string{}, Result: TYPE_STRING, Fn: osHostname},
+	}, nil))
+}
+
+// SetArgs makes args, the command line arguments after the script name,
+// available to the script as the list os.args.
+func (i *Interpreter) SetArgs(args []string) {
+	list := &List{Elements: make([]Value, len(args))}
+	for idx, arg := range args {
+		list.Elements[idx] = Value{TYPE_STRING, arg}
+	}
+	module := &Module{Name: "os", Members: map[string]Value{"args": {TYPE_LIST, list}}}
+	for name, member := range modules["os"].Members {
+		module.Members[name] = member
+	}
+	i.env.Declare("os", TYPE_MODULE, module, 0)
+}
+
+// getenv(name, default) is the value of an environment variable, or
+// default (null if omitted) when it is not set.
+func osGetenv(i *Interpreter, args []Value, line int) (string, any, error) {
+	if val, ok := os.LookupEnv(args[0].Value.(string)); ok {
+		return TYPE_STRING, val, nil
+	}
+	if len(args) == 2 {
+		return args[1].Type, args[1].Value, nil
+	}
+	return TYPE_NULL, nullValue, nil
+}
+
+func osSetenv(i *Interpreter, args []Value, line int) (string, any, error) {
+	if err := os.Setenv(args[0].Value.(string), args[1].Value.(string)); err != nil {
+		return errorValue(err)
+	}
+	return TYPE_NULL, nullValue, nil
+}
+
+// exit(code) ends the script with an exit status, 0 if omitted.
+func osExit(i *Interpreter, args []Value, line int) (string, any, error) {
+	code := 0
+	if len(args) == 1 {
+		var err error
+		if code, err = intArg("os.exit", "status", args[0], line); err != nil {
+			return "", 0, err
+		}
+		if code < 0 || code > 255 {
+			return "", 0, fmt.Errorf("os.exit status must be between 0 and 255, got %d at line %d", code, line)
+		}
+	}
+	return "", 0, &ExitError{Code: code}
+}
+
+func osCwd(i *Interpreter, args []Value, line int) (string, any, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return errorValue(err)
+	}
+	return TYPE_STRING, dir, nil
+}
+
+func osHostname(i *Interpreter, args []Value, line int) (string, any, error) {
+	name, err := os.Hostname()
+	if err != nil {
+		return errorValue(err)
+	}
+	return TYPE_STRING, name, nil
+}
@@ -0,0 +1,38 @@
+package interpreter
+
+import "path/filepath"
+
+// The path module manipulates paths as strings, using the separator of
+// the host platform. It never touches the file system.
+func init() {
+	RegisterModule(NewModule("path", []*Builtin{
+		{Name: "join", Params: []string{TYPE_STRING}, Variadic: true, Result: TYPE_STRING, Fn: pathJoin},
+		{Name: "base", Params: []string{TYPE_STRING}, Result: TYPE_STRING, Fn: pathBase},
+		{Name: "dir", Params: []string{TYPE_STRING}, Result: TYPE_STRING, Fn: pathDir},
+		{Name: "ext", Params: []string{TYPE_STRING}, Result: TYPE_STRING, Fn: pathExt},
+	}, nil))
+}
+
+// join(a, b, ...) joins path elements with the separator and cleans the result.
+func pathJoin(i *Interpreter, args []Value, line int) (string, any, error) {
+	elements := make([]string, len(args))
+	for idx, arg := range args {
+		elements[idx] = arg.Value.(string)
+	}
+	return TYPE_STRING, filepath.Join(elements...), nil
+}
+
+// base(p) is the last element of p, e.g. "b.txt" for "a/b.txt".
+func pathBase(i *Interpreter, args []Value, line int) (string, any, error) {
+	return TYPE_STRING, filepath.Base(args[0].Value.(string)), nil
+}
+
+// dir(p) is all but the last element of p, e.g. "a" for "a/b.txt".
+func pathDir(i *Interpreter, args []Value, line int) (string, any, error) {
+	return TYPE_STRING, filepath.Dir(args[0].Value.(string)), nil
+}
+
+// ext(p) is the extension of p including its dot, e.g. ".txt", or "".
+func pathExt(i *Interpreter, args []Value, line int) (string, any, error) {
+	return TYPE_STRING, filepath.Ext(args[0].Value.(string)), nil
+}
@@ -0,0 +1,316 @@
+package interpreter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// procResultType is the record type of what proc.run and p.wait() return.
+// code is the exit status, or -1 if the process was killed by a signal.
+var procResultType = &RecordType{Name: "ProcResult", Fields: []string{"code", "stdout", "stderr"}}
+
+// procOptions are the settings accepted in the options map of proc.run
+// and proc.start.
+type procOptions struct {
+	stdin   string
+	env     []string
+	cwd     string
+	timeout float64 // seconds, 0 for none
+}
+
+// Process is a running subprocess started by proc.start. Its standard
+// output can be read line by line, with p.read_line() or by iterating
+// over p, before p.wait() collects the result.
+type Process struct {
+	Command string
+	cmd     *exec.Cmd
+	pipe    *os.File // read end of standard output
+	stdout  *bufio.Reader
+	stderr  *bytes.Buffer
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timeout float64
+	result  *Record // set once the process has been waited for
+}
+
+func (p *Process) String() string {
+	return fmt.Sprintf("<process %s>", p.Command)
+}
+
+// Get returns one of the process's methods, or its pid.
+func (p *Process) Get(field string, line int) (string, any, error) {
+	var method *Builtin
+	switch field {
+	case "pid":
+		return TYPE_NUMBER, float64(p.cmd.Process.Pid), nil
+	case "read_line":
+		method = &Builtin{Params: []string{}, Result: TYPE_STRING, Fn: p.readLine}
+	case "wait":
+		method = &Builtin{Params: []string{}, Result: TYPE_RECORD, Fn: p.wait}
+	case "kill":
+		method = &Builtin{Params: []string{}, Result: TYPE_NULL, Fn: p.kill}
+	default:
+		return "", 0, fmt.Errorf("process has no field %q at line %d", field, line)
+	}
+	method.Name = "process." + field
+	return TYPE_BUILTIN, method, nil
+}
+
+// Close kills the process if it is still running and waits for it.
+func (p *Process) Close() error {
+	if p.result == nil {
+		p.cancel()
+		p.finish()
+	}
+	return nil
+}
+
+// readLine returns the next line of standard output, or null at its end.
+func (p *Process) readLine(i *Interpreter, args []Value, line int) (string, any, error) {
+	if p.result != nil {
+		return TYPE_NULL, nullValue, nil
+	}
+	return readLine(p.stdout)
+}
+
+// wait waits for the process to exit. Output that was not read line by
+// line is returned in the result's stdout.
+func (p *Process) wait(i *Interpreter, args []Value, line int) (string, any, error) {
+	if p.result == nil {
+		if err := p.finish(); err != nil {
+			return errorValue(err)
+		}
+	}
+	return TYPE_RECORD, p.result, nil
+}
+
+func (p *Process) kill(i *Interpreter, args []Value, line int) (string, any, error) {
+	if p.result == nil {
+		p.cancel()
+	}
+	return TYPE_NULL, nullValue, nil
+}
+
+// finish waits for the process, collecting the rest of standard output
+// meanwhile, and records its result.
+func (p *Process) finish() error {
+	defer p.cancel()
+	defer p.pipe.Close()
+	output := make(chan []byte, 1)
+	go func() {
+		data, _ := io.ReadAll(p.stdout)
+		output <- data
+	}()
+	err := p.cmd.Wait()
+	// A child that outlives the process may hold the pipe open indefinitely
+	p.pipe.SetReadDeadline(time.Now().Add(p.cmd.WaitDelay))
+	stdout := <-output
+	result, err := procResult(p.Command, err, p.cmd.ProcessState, p.ctx, p.timeout, string(stdout), p.stderr.String())
+	p.result = result
+	if result == nil {
+		// Keep later calls from waiting again
+		p.result = &Record{Type: procResultType, Types: []string{TYPE_NUMBER, TYPE_STRING, TYPE_STRING}, Values: []any{float64(-1), "", ""}}
+	}
+	return err
+}
+
+// procResult builds the result of a finished process from what waiting
+// for it returned. A non-zero exit status is not a failure. Only a process
+// killed after its deadline timed out: exec reports the deadline even for
+// one that exited on its own before being waited for.
+func procResult(command string, err error, state *os.ProcessState, ctx context.Context, timeout float64, stdout, stderr string) (*Record, error) {
+	if state == nil {
+		// The process could not be started or waited for
+		return nil, err
+	}
+	if !state.Exited() && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%s timed out after %vs", command, timeout)
+	}
+	return &Record{
+		Type:   procResultType,
+		Types:  []string{TYPE_NUMBER, TYPE_STRING, TYPE_STRING},
+		Values: []any{float64(state.ExitCode()), stdout, stderr},
+	}, nil
+}
+
+// The proc module. Every call is first checked against the interpreter's
+// ProcPolicy. Failures to start a process and timeouts are returned as
+// error values.
+func init() {
+	RegisterModule(NewModule("proc", []*Builtin{
+		{Name: "run", Params: []string{TYPE_STRING, TYPE_LIST, TYPE_MAP}, Optional: 2, Result: TYPE_RECORD, Fn: procRun},
+		{Name: "start", Params: []string{TYPE_STRING, TYPE_LIST, TYPE_MAP}, Optional: 2, Result: TYPE_PROCESS, Fn: procStart},
+	}, nil))
+}
+
+// procSpec is a command to run, as given to proc.run or proc.start.
+type procSpec struct {
+	name string
+	args []string
+	opts procOptions
+}
+
+// parseProcSpec reads the arguments of proc.run or proc.start and checks the
+// command against the policy.
+func (i *Interpreter) parseProcSpec(fn string, args []Value, line int) (procSpec, error) {
+	spec := procSpec{name: args[0].Value.(string), args: []string{}}
+	if len(args) > 1 {
+		for idx, arg := range args[1].Value.(*List).Elements {
+			if arg.Type != TYPE_STRING {
+				return spec, fmt.Errorf("%s expects a list of string arguments, got %s at position %d at line %d", fn, arg.Type, idx, line)
+			}
+			spec.args = append(spec.args, arg.Value.(string))
+		}
+	}
+	if len(args) > 2 {
+		var err error
+		if spec.opts, err = parseProcOptions(fn, args[2].Value.(*Map), line); err != nil {
+			return spec, err
+		}
+	}
+	if i.ProcPolicy != nil {
+		if err := i.ProcPolicy(spec.name, spec.args); err != nil {
+			return spec, fmt.Errorf("%s of %q denied: %v at line %d", fn, spec.name, err, line)
+		}
+	}
+	return spec, nil
+}
+
+// command prepares the process. It is killed when the returned context
+// is cancelled or its timeout expires.
+func (spec procSpec) command() (*exec.Cmd, context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if spec.opts.timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(spec.opts.timeout*float64(time.Second)))
+	}
+	cmd := exec.CommandContext(ctx, spec.name, spec.args...)
+	cmd.Stdin = strings.NewReader(spec.opts.stdin)
+	cmd.Dir = spec.opts.cwd
+	if spec.opts.env != nil {
+		cmd.Env = append(os.Environ(), spec.opts.env...)
+	}
+	// Don't wait forever for output from children that outlive the process
+	cmd.WaitDelay = time.Second
+	return cmd, ctx, cancel
+}
+
+// maxProcTimeout is the longest timeout, in seconds, that a time.Duration
+// can hold.
+const maxProcTimeout = float64(math.MaxInt64 / time.Second)
+
+func parseProcOptions(fn string, options *Map, line int) (procOptions, error) {
+	var opts procOptions
+	for idx, key := range options.keys {
+		val := options.values[idx]
+		mismatch := func(expected string) error {
+			return fmt.Errorf("%s option %s must be %s, got %s at line %d", fn, key, expected, val.Type, line)
+		}
+		switch key.Value {
+		case "stdin":
+			if val.Type != TYPE_STRING {
+				return opts, mismatch("a string")
+			}
+			opts.stdin = val.Value.(string)
+		case "cwd":
+			if val.Type != TYPE_STRING {
+				return opts, mismatch("a string")
+			}
+			opts.cwd = val.Value.(string)
+		case "timeout":
+			if val.Type != TYPE_NUMBER || !(val.Value.(float64) > 0) {
+				return opts, mismatch("a positive number of seconds")
+			}
+			if val.Value.(float64) > maxProcTimeout {
+				return opts, fmt.Errorf("%s option timeout cannot exceed %v seconds, got %v at line %d", fn, maxProcTimeout, val.Value, line)
+			}
+			opts.timeout = val.Value.(float64)
+		case "env":
+			if val.Type != TYPE_MAP {
+				return opts, mismatch("a map of strings")
+			}
+			env := val.Value.(*Map)
+			opts.env = []string{}
+			for idx, name := range env.keys {
+				if name.Type != TYPE_STRING || env.values[idx].Type != TYPE_STRING {
+					return opts, fmt.Errorf("%s option env must map strings to strings at line %d", fn, line)
+				}
+				opts.env = append(opts.env, name.Value.(string)+"="+env.values[idx].Value.(string))
+			}
+		default:
+			return opts, fmt.Errorf("%s has no option %s at line %d", fn, key, line)
+		}
+	}
+	return opts, nil
+}
+
+// run(cmd, args, options) runs a command to completion and returns a
+// ProcResult with its exit code, stdout and stderr. The options map may
+// set stdin (a string), env (extra variables), cwd and timeout (seconds).
+func procRun(i *Interpreter, args []Value, line int) (string, any, error) {
+	spec, err := i.parseProcSpec("proc.run", args, line)
+	if err != nil {
+		return "", 0, err
+	}
+	cmd, ctx, cancel := spec.command()
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err = cmd.Run()
+	result, err := procResult(spec.name, err, cmd.ProcessState, ctx, spec.opts.timeout, stdout.String(), stderr.String())
+	if err != nil {
+		return errorValue(err)
+	}
+	return TYPE_RECORD, result, nil
+}
+
+// start(cmd, args, options) starts a command and returns a process whose
+// output can be read while it runs. It takes the same options as run.
+// Processes still running when the script ends are killed by
+// Interpreter.Close.
+func procStart(i *Interpreter, args []Value, line int) (string, any, error) {
+	spec, err := i.parseProcSpec("proc.start", args, line)
+	if err != nil {
+		return "", 0, err
+	}
+	cmd, ctx, cancel := spec.command()
+
+	// The process writes straight into a pipe rather than through exec,
+	// whose Wait would close it before the output had been read
+	stdout, writer, err := os.Pipe()
+	if err != nil {
+		cancel()
+		return errorValue(err)
+	}
+	stderr := &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = writer, stderr
+	err = cmd.Start()
+	writer.Close()
+	if err != nil {
+		stdout.Close()
+		cancel()
+		return errorValue(err)
+	}
+
+	process := &Process{
+		Command: spec.name,
+		cmd:     cmd,
+		pipe:    stdout,
+		stdout:  bufio.NewReader(stdout),
+		stderr:  stderr,
+		ctx:     ctx,
+		cancel:  cancel,
+		timeout: spec.opts.timeout,
+	}
+	i.handles = append(i.handles, process)
+	return TYPE_PROCESS, process, nil
+}
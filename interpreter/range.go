@@ -0,0 +1,65 @@
+package interpreter
+
+import (
+	"fmt"
+	"inky/ast"
+	"math"
+)
+
+// Range is a lazy sequence of consecutive integers. Its elements are never
+// materialized, so 1..1000000000 costs the same as 1..3.
+type Range struct {
+	Start     int
+	End       int
+	Inclusive bool
+}
+
+func (r *Range) String() string {
+	if r.Inclusive {
+		return fmt.Sprintf("%d..%d", r.Start, r.End)
+	}
+	return fmt.Sprintf("%d..<%d", r.Start, r.End)
+}
+
+// Stop returns the exclusive upper bound of the range.
+func (r *Range) Stop() int {
+	if r.Inclusive {
+		return r.End + 1
+	}
+	return r.End
+}
+
+// Contains reports whether num is one of the integers in the range.
+func (r *Range) Contains(num float64) bool {
+	return num == math.Trunc(num) && num >= float64(r.Start) && num < float64(r.Stop())
+}
+
+func (i *Interpreter) visitRange(node *ast.Range) (string, any, error) {
+	startType, startVal, err := i.Interpret(node.Start)
+	if err != nil {
+		return "", 0, err
+	}
+	endType, endVal, err := i.Interpret(node.End)
+	if err != nil {
+		return "", 0, err
+	}
+	start, err := toInt(startType, startVal, node.Line)
+	if err != nil {
+		return "", 0, fmt.Errorf("range start: %w", err)
+	}
+	end, err := toInt(endType, endVal, node.Line)
+	if err != nil {
+		return "", 0, fmt.Errorf("range end: %w", err)
+	}
+	return TYPE_RANGE, &Range{Start: start, End: end, Inclusive: node.Inclusive}, nil
+}
+
+// rangeBounds converts a range used as a subscript into slice bounds, so
+// s[1..3] is s[1:4] and s[1..-1] runs to the end like s[1:].
+func rangeBounds(r *Range) (*int, *int) {
+	start, stop := r.Start, r.Stop()
+	if r.Inclusive && r.End == -1 {
+		return &start, nil
+	}
+	return &start, &stop
+}
@@ -0,0 +1,184 @@
+package interpreter
+
+import (
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+)
+
+// Regex is a compiled regular expression, as returned by re.compile.
+type Regex struct {
+	re *regexp.Regexp
+}
+
+func (r *Regex) String() string {
+	return fmt.Sprintf("<regex %s>", r.re)
+}
+
+// Get returns the pattern the regex was compiled from.
+func (r *Regex) Get(field string, line int) (string, any, error) {
+	if field != "pattern" {
+		return "", 0, fmt.Errorf("regex has no field %q at line %d", field, line)
+	}
+	return TYPE_STRING, r.re.String(), nil
+}
+
+// matchType is the record type of a match. start and end count characters;
+// groups lists the capture groups in order and named maps the named ones.
+// A group that did not take part in the match is null.
+var matchType = &RecordType{Name: "Match", Fields: []string{"text", "start", "end", "groups", "named"}}
+
+// The re module, using Go's regexp syntax. Functions take a pattern
+// either as a string or as a Regex from re.compile; pattern strings are
+// compiled once per interpreter and cached. A pattern that does not
+// compile is returned as an error value carrying the regexp error text.
+func init() {
+	RegisterModule(NewModule("re", []*Builtin{
+		{Name: "compile", Params: []string{TYPE_STRING}, Result: TYPE_REGEX, Fn: reCompile},
+		{Name: "match", Params: []string{TYPE_ANY, TYPE_STRING}, Result: TYPE_BOOL, Fn: reMatch},
+		{Name: "find", Params: []string{TYPE_ANY, TYPE_STRING}, Result: TYPE_RECORD, Fn: reFind},
+		{Name: "find_all", Params: []string{TYPE_ANY, TYPE_STRING}, Result: TYPE_LIST, Fn: reFindAll},
+		{Name: "replace", Params: []string{TYPE_ANY, TYPE_STRING, TYPE_ANY}, Result: TYPE_STRING, Fn: reReplace},
+	}, nil))
+}
+
+// pattern returns the regexp for a pattern argument. A pattern string that
+// does not compile is returned as an *Error for the script to handle.
+func (i *Interpreter) pattern(fn string, arg Value, line int) (*regexp.Regexp, *Error, error) {
+	switch arg.Type {
+	case TYPE_REGEX:
+		return arg.Value.(*Regex).re, nil, nil
+	case TYPE_STRING:
+		source := arg.Value.(string)
+		if re, ok := i.regexps[source]; ok {
+			return re, nil, nil
+		}
+		re, err := regexp.Compile(source)
+		if err != nil {
+			return nil, &Error{Message: err.Error()}, nil
+		}
+		i.regexps[source] = re
+		return re, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("%s expects a pattern string or regex, got %s at line %d", fn, arg.Type, line)
+	}
+}
+
+func reCompile(i *Interpreter, args []Value, line int) (string, any, error) {
+	re, invalid, _ := i.pattern("re.compile", args[0], line)
+	if invalid != nil {
+		return TYPE_ERROR, invalid, nil
+	}
+	return TYPE_REGEX, &Regex{re: re}, nil
+}
+
+// match(pattern, s) reports whether pattern matches anywhere in s. Anchor
+// the pattern with ^ and $ to match all of s.
+func reMatch(i *Interpreter, args []Value, line int) (string, any, error) {
+	re, invalid, err := i.pattern("re.match", args[0], line)
+	if err != nil {
+		return "", 0, err
+	} else if invalid != nil {
+		return TYPE_ERROR, invalid, nil
+	}
+	return TYPE_BOOL, re.MatchString(args[1].Value.(string)), nil
+}
+
+// find(pattern, s) returns the first match in s as a Match record, or null.
+func reFind(i *Interpreter, args []Value, line int) (string, any, error) {
+	re, invalid, err := i.pattern("re.find", args[0], line)
+	if err != nil {
+		return "", 0, err
+	} else if invalid != nil {
+		return TYPE_ERROR, invalid, nil
+	}
+	s := args[1].Value.(string)
+	loc := re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return TYPE_NULL, nullValue, nil
+	}
+	return TYPE_RECORD, matchRecord(re, s, loc), nil
+}
+
+// find_all(pattern, s) returns every non-overlapping match in s.
+func reFindAll(i *Interpreter, args []Value, line int) (string, any, error) {
+	re, invalid, err := i.pattern("re.find_all", args[0], line)
+	if err != nil {
+		return "", 0, err
+	} else if invalid != nil {
+		return TYPE_ERROR, invalid, nil
+	}
+	s := args[1].Value.(string)
+	list := &List{Elements: []Value{}}
+	for _, loc := range re.FindAllStringSubmatchIndex(s, -1) {
+		list.Elements = append(list.Elements, Value{TYPE_RECORD, matchRecord(re, s, loc)})
+	}
+	return TYPE_LIST, list, nil
+}
+
+// replace(pattern, s, replacement) replaces every match in s. replacement
+// is either a string, in which $1 or ${name} stand for groups, or a
+// callable such as strings.upper, which is called with the text of each
+// match and must return a string.
+func reReplace(i *Interpreter, args []Value, line int) (string, any, error) {
+	re, invalid, err := i.pattern("re.replace", args[0], line)
+	if err != nil {
+		return "", 0, err
+	} else if invalid != nil {
+		return TYPE_ERROR, invalid, nil
+	}
+	s := args[1].Value.(string)
+	if args[2].Type == TYPE_STRING {
+		return TYPE_STRING, re.ReplaceAllString(s, args[2].Value.(string)), nil
+	} else if !isCallable(args[2].Type) {
+		return "", 0, fmt.Errorf("re.replace expects a replacement string or callable, got %s at line %d", args[2].Type, line)
+	}
+
+	var callErr error
+	result := re.ReplaceAllStringFunc(s, func(text string) string {
+		if callErr != nil {
+			return text
+		}
+		typ, val, err := i.CallValue(args[2], []Value{{TYPE_STRING, text}}, line)
+		if err != nil {
+			callErr = err
+		} else if typ != TYPE_STRING {
+			callErr = fmt.Errorf("re.replace callback must return a string, got %s at line %d", typ, line)
+		} else {
+			return val.(string)
+		}
+		return text
+	})
+	if callErr != nil {
+		return "", 0, callErr
+	}
+	return TYPE_STRING, result, nil
+}
+
+// matchRecord builds a Match from the byte offsets FindStringSubmatchIndex
+// returns for s.
+func matchRecord(re *regexp.Regexp, s string, loc []int) *Record {
+	position := func(offset int) float64 {
+		return float64(utf8.RuneCountInString(s[:offset]))
+	}
+	group := func(n int) Value {
+		if loc[2*n] < 0 {
+			return Value{TYPE_NULL, nullValue}
+		}
+		return Value{TYPE_STRING, s[loc[2*n]:loc[2*n+1]]}
+	}
+
+	groups := &List{Elements: []Value{}}
+	named := NewMap()
+	for n, name := range re.SubexpNames()[1:] {
+		groups.Elements = append(groups.Elements, group(n+1))
+		if name != "" {
+			named.Set(Value{TYPE_STRING, name}, group(n+1), 0)
+		}
+	}
+	return &Record{
+		Type:   matchType,
+		Types:  []string{TYPE_STRING, TYPE_NUMBER, TYPE_NUMBER, TYPE_LIST, TYPE_MAP},
+		Values: []any{s[loc[0]:loc[1]], position(loc[0]), position(loc[1]), groups, named},
+	}
+}
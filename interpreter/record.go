@@ -0,0 +1,131 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RecordType is the runtime value of a record declaration. Calling it
+// constructs a Record with one argument per field.
+type RecordType struct {
+	Name   string
+	Fields []string
+}
+
+func (r *RecordType) String() string {
+	return fmt.Sprintf("<record %s>", r.Name)
+}
+
+func (r *RecordType) fieldIndex(name string) int {
+	for idx, field := range r.Fields {
+		if field == name {
+			return idx
+		}
+	}
+	return -1
+}
+
+// Record is an instance of a RecordType. Records are mutable and shared by reference.
+type Record struct {
+	Type   *RecordType
+	Types  []string
+	Values []any
+	frozen bool // reachable from a constant
+}
+
+func (r *Record) String() string {
+	return formatValue(TYPE_RECORD, r)
+}
+
+// Get returns the type and value of a field.
+func (r *Record) Get(field string, line int) (string, any, error) {
+	idx := r.Type.fieldIndex(field)
+	if idx < 0 {
+		return "", 0, fmt.Errorf("record %s has no field %q at line %d", r.Type.Name, field, line)
+	}
+	return r.Types[idx], r.Values[idx], nil
+}
+
+// Set overwrites a field.
+func (r *Record) Set(field string, typ string, value any, line int) error {
+	idx := r.Type.fieldIndex(field)
+	if idx < 0 {
+		return fmt.Errorf("record %s has no field %q at line %d", r.Type.Name, field, line)
+	}
+	if r.frozen {
+		return fmt.Errorf("cannot assign to field %q of a constant %s at line %d", field, r.Type.Name, line)
+	}
+	r.Types[idx], r.Values[idx] = typ, value
+	return nil
+}
+
+// formatValue renders a value nested inside another, quoting strings so
+// that Point{name: "a"} stays readable.
+func formatValue(typ string, value any) string {
+	f := formatter{active: map[any]bool{}}
+	return f.format(typ, value)
+}
+
+// formatter renders values that may contain themselves. active holds the
+// lists, maps, records and enum values being rendered further out; one met
+// again inside itself is abbreviated, e.g. to [...], as json.encode
+// refuses such values.
+type formatter struct {
+	active map[any]bool
+}
+
+func (f *formatter) format(typ string, value any) string {
+	switch typ {
+	case TYPE_STRING:
+		return fmt.Sprintf("%q", value)
+	case TYPE_LIST, TYPE_MAP, TYPE_RECORD, TYPE_ENUM:
+		return f.container(value)
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
+
+func (f *formatter) container(value any) string {
+	if f.active[value] {
+		switch value := value.(type) {
+		case *List:
+			return "[...]"
+		case *Map:
+			return "{...}"
+		case *Record:
+			return value.Type.Name + "{...}"
+		default:
+			return value.(*EnumValue).Variant.Name + "(...)"
+		}
+	}
+	f.active[value] = true
+	defer delete(f.active, value)
+
+	var parts []string
+	switch value := value.(type) {
+	case *List:
+		for _, elem := range value.Elements {
+			parts = append(parts, f.format(elem.Type, elem.Value))
+		}
+		return fmt.Sprintf("[%s]", strings.Join(parts, ", "))
+	case *Map:
+		for idx, key := range value.keys {
+			parts = append(parts, fmt.Sprintf("%s: %s", f.format(key.Type, key.Value), f.format(value.values[idx].Type, value.values[idx].Value)))
+		}
+		return fmt.Sprintf("{%s}", strings.Join(parts, ", "))
+	case *Record:
+		for idx, field := range value.Type.Fields {
+			parts = append(parts, fmt.Sprintf("%s: %s", field, f.format(value.Types[idx], value.Values[idx])))
+		}
+		return fmt.Sprintf("%s{%s}", value.Type.Name, strings.Join(parts, ", "))
+	default:
+		enum := value.(*EnumValue)
+		if len(enum.Payload) == 0 {
+			return enum.Variant.Name
+		}
+		for _, val := range enum.Payload {
+			parts = append(parts, f.format(val.Type, val.Value))
+		}
+		return fmt.Sprintf("%s(%s)", enum.Variant.Name, strings.Join(parts, ", "))
+	}
+}
@@ -0,0 +1,209 @@
+package interpreter
+
+import (
+	"fmt"
+	"inky/ast"
+	"math"
+)
+
+// Indexing and slicing of strings and lists follow Python's rules. Positions start at 0 and a
+// negative position counts from the end, so -1 is the last element. A
+// single index outside the sequence is an error. Slice bounds outside the
+// sequence are clamped to it, so s[2:100] and s[-100:2] never fail.
+// Strings are indexed and sliced by rune, not by byte.
+
+// toInt converts a runtime value to an integer position.
+func toInt(typ string, val any, line int) (int, error) {
+	if typ != TYPE_NUMBER {
+		return 0, fmt.Errorf("index must be a number, got %s at line %d", typ, line)
+	}
+	num := val.(float64)
+	if num != math.Trunc(num) {
+		return 0, fmt.Errorf("index must be an integer, got %v at line %d", num, line)
+	}
+	return int(num), nil
+}
+
+// sliceIndices returns the positions selected by [start:stop:step] over a
+// sequence of length n. Nil bounds take their defaults for the step's direction.
+func sliceIndices(start, stop, step *int, n int, line int) ([]int, error) {
+	stride := 1
+	if step != nil {
+		stride = *step
+	}
+	if stride == 0 {
+		return nil, fmt.Errorf("slice step cannot be zero at line %d", line)
+	}
+
+	// clamp resolves a bound, counting negatives from the end and limiting
+	// the result to [lo, hi].
+	clamp := func(bound *int, def, lo, hi int) int {
+		if bound == nil {
+			return def
+		}
+		pos := *bound
+		if pos < 0 {
+			pos += n
+		}
+		return max(lo, min(pos, hi))
+	}
+
+	positions := []int{}
+	if stride > 0 {
+		from, to := clamp(start, 0, 0, n), clamp(stop, n, 0, n)
+		for pos := from; pos < to; pos += stride {
+			positions = append(positions, pos)
+		}
+	} else {
+		from, to := clamp(start, n-1, -1, n-1), clamp(stop, -1, -1, n-1)
+		for pos := from; pos > to; pos += stride {
+			positions = append(positions, pos)
+		}
+	}
+	return positions, nil
+}
+
+// position resolves a possibly negative index into a sequence of length n.
+func position(idxType string, idxVal any, n int, what string, line int) (int, error) {
+	idx, err := toInt(idxType, idxVal, line)
+	if err != nil {
+		return 0, err
+	}
+	pos := idx
+	if pos < 0 {
+		pos += n
+	}
+	if pos < 0 || pos >= n {
+		return 0, fmt.Errorf("index %d out of range for %s of length %d at line %d", idx, what, n, line)
+	}
+	return pos, nil
+}
+
+func (i *Interpreter) visitIndex(node *ast.Index) (string, any, error) {
+	objType, obj, err := i.Interpret(node.Object)
+	if err != nil {
+		return "", 0, err
+	}
+	idxType, idxVal, err := i.Interpret(node.Index)
+	if err != nil {
+		return "", 0, err
+	}
+	if objType == TYPE_MAP {
+		// A missing key reads as null, so m[k] or default works
+		if val, ok := obj.(*Map).Get(Value{idxType, idxVal}); ok {
+			return val.Type, val.Value, nil
+		}
+		return TYPE_NULL, nullValue, nil
+	}
+	if idxType == TYPE_RANGE {
+		start, stop := rangeBounds(idxVal.(*Range))
+		return sliceValue(objType, obj, start, stop, nil, node.Line)
+	}
+
+	switch objType {
+	case TYPE_STRING:
+		runes := []rune(obj.(string))
+		pos, err := position(idxType, idxVal, len(runes), "string", node.Line)
+		if err != nil {
+			return "", 0, err
+		}
+		return TYPE_STRING, string(runes[pos]), nil
+	case TYPE_LIST:
+		elements := obj.(*List).Elements
+		pos, err := position(idxType, idxVal, len(elements), "list", node.Line)
+		if err != nil {
+			return "", 0, err
+		}
+		return elements[pos].Type, elements[pos].Value, nil
+	default:
+		return "", 0, fmt.Errorf("cannot index %s at line %d", objType, node.Line)
+	}
+}
+
+// assignIndex implements xs[i] := v and m[k] := v.
+func (i *Interpreter) assignIndex(node *ast.Index, value Value) error {
+	objType, obj, err := i.Interpret(node.Object)
+	if err != nil {
+		return err
+	}
+	idxType, idxVal, err := i.Interpret(node.Index)
+	if err != nil {
+		return err
+	}
+
+	switch objType {
+	case TYPE_MAP:
+		m := obj.(*Map)
+		if m.frozen {
+			return fmt.Errorf("cannot assign to a key of a constant map at line %d", node.Line)
+		}
+		return m.Set(Value{idxType, idxVal}, value, node.Line)
+	case TYPE_LIST:
+		list := obj.(*List)
+		if list.frozen {
+			return fmt.Errorf("cannot assign to an index of a constant list at line %d", node.Line)
+		}
+		elements := list.Elements
+		pos, err := position(idxType, idxVal, len(elements), "list", node.Line)
+		if err != nil {
+			return err
+		}
+		elements[pos] = value
+		return nil
+	default:
+		return fmt.Errorf("cannot assign to an index of %s at line %d", objType, node.Line)
+	}
+}
+
+func (i *Interpreter) visitSlice(node *ast.Slice) (string, any, error) {
+	objType, obj, err := i.Interpret(node.Object)
+	if err != nil {
+		return "", 0, err
+	}
+	bounds := make([]*int, 3)
+	for idx, expr := range []ast.Expr{node.Start, node.Stop, node.Step} {
+		if expr == nil {
+			continue
+		}
+		typ, val, err := i.Interpret(expr)
+		if err != nil {
+			return "", 0, err
+		}
+		bound, err := toInt(typ, val, node.Line)
+		if err != nil {
+			return "", 0, err
+		}
+		bounds[idx] = &bound
+	}
+
+	return sliceValue(objType, obj, bounds[0], bounds[1], bounds[2], node.Line)
+}
+
+func sliceValue(objType string, obj any, start, stop, step *int, line int) (string, any, error) {
+	switch objType {
+	case TYPE_STRING:
+		runes := []rune(obj.(string))
+		positions, err := sliceIndices(start, stop, step, len(runes), line)
+		if err != nil {
+			return "", 0, err
+		}
+		result := make([]rune, len(positions))
+		for idx, pos := range positions {
+			result[idx] = runes[pos]
+		}
+		return TYPE_STRING, string(result), nil
+	case TYPE_LIST:
+		elements := obj.(*List).Elements
+		positions, err := sliceIndices(start, stop, step, len(elements), line)
+		if err != nil {
+			return "", 0, err
+		}
+		result := &List{Elements: make([]Value, len(positions))}
+		for idx, pos := range positions {
+			result.Elements[idx] = elements[pos]
+		}
+		return TYPE_LIST, result, nil
+	default:
+		return "", 0, fmt.Errorf("cannot slice %s at line %d", objType, line)
+	}
+}
@@ -0,0 +1,183 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// The strings module. Positions and lengths count characters (runes),
+// not bytes, to agree with string indexing and slicing.
+func init() {
+	RegisterModule(NewModule("strings", []*Builtin{
+		{Name: "upper", Params: []string{TYPE_STRING}, Result: TYPE_STRING, Fn: stringsUpper},
+		{Name: "lower", Params: []string{TYPE_STRING}, Result: TYPE_STRING, Fn: stringsLower},
+		{Name: "trim", Params: []string{TYPE_STRING, TYPE_STRING}, Optional: 1, Result: TYPE_STRING, Fn: stringsTrim},
+		{Name: "split", Params: []string{TYPE_STRING, TYPE_STRING}, Optional: 1, Result: TYPE_LIST, Fn: stringsSplit},
+		{Name: "join", Params: []string{TYPE_LIST, TYPE_STRING}, Optional: 1, Result: TYPE_STRING, Fn: stringsJoin},
+		{Name: "find", Params: []string{TYPE_STRING, TYPE_STRING}, Result: TYPE_NUMBER, Fn: stringsFind},
+		{Name: "replace", Params: []string{TYPE_STRING, TYPE_STRING, TYPE_STRING, TYPE_NUMBER}, Optional: 1, Result: TYPE_STRING, Fn: stringsReplace},
+		{Name: "starts_with", Params: []string{TYPE_STRING, TYPE_STRING}, Result: TYPE_BOOL, Fn: stringsStartsWith},
+		{Name: "ends_with", Params: []string{TYPE_STRING, TYPE_STRING}, Result: TYPE_BOOL, Fn: stringsEndsWith},
+		{Name: "repeat", Params: []string{TYPE_STRING, TYPE_NUMBER}, Result: TYPE_STRING, Fn: stringsRepeat},
+		{Name: "pad_left", Params: []string{TYPE_STRING, TYPE_NUMBER, TYPE_STRING}, Optional: 1, Result: TYPE_STRING, Fn: stringsPadLeft},
+		{Name: "pad_right", Params: []string{TYPE_STRING, TYPE_NUMBER, TYPE_STRING}, Optional: 1, Result: TYPE_STRING, Fn: stringsPadRight},
+		{Name: "len", Params: []string{TYPE_STRING}, Result: TYPE_NUMBER, Fn: stringsLen},
+		{Name: "sub", Params: []string{TYPE_STRING, TYPE_NUMBER, TYPE_NUMBER}, Optional: 1, Result: TYPE_STRING, Fn: stringsSub},
+	}, nil))
+}
+
+// maxStringLen bounds, in bytes, the strings that repeat and padding build,
+// so that a mistaken count is an error rather than an attempt to allocate
+// all of memory.
+const maxStringLen = 1 << 30
+
+func stringsUpper(i *Interpreter, args []Value, line int) (string, any, error) {
+	return TYPE_STRING, strings.ToUpper(args[0].Value.(string)), nil
+}
+
+func stringsLower(i *Interpreter, args []Value, line int) (string, any, error) {
+	return TYPE_STRING, strings.ToLower(args[0].Value.(string)), nil
+}
+
+// trim(s, chars) removes leading and trailing characters found in chars,
+// or whitespace when chars is omitted.
+func stringsTrim(i *Interpreter, args []Value, line int) (string, any, error) {
+	if len(args) == 1 {
+		return TYPE_STRING, strings.TrimSpace(args[0].Value.(string)), nil
+	}
+	return TYPE_STRING, strings.Trim(args[0].Value.(string), args[1].Value.(string)), nil
+}
+
+// split(s, sep) cuts s around each sep. Without sep it splits on runs of
+// whitespace, and an empty sep splits s into characters.
+func stringsSplit(i *Interpreter, args []Value, line int) (string, any, error) {
+	var parts []string
+	if len(args) == 1 {
+		parts = strings.Fields(args[0].Value.(string))
+	} else {
+		parts = strings.Split(args[0].Value.(string), args[1].Value.(string))
+	}
+	list := &List{Elements: make([]Value, len(parts))}
+	for idx, part := range parts {
+		list.Elements[idx] = Value{TYPE_STRING, part}
+	}
+	return TYPE_LIST, list, nil
+}
+
+// join(list, sep) concatenates a list of strings with sep between them.
+func stringsJoin(i *Interpreter, args []Value, line int) (string, any, error) {
+	elements := args[0].Value.(*List).Elements
+	parts := make([]string, len(elements))
+	for idx, elem := range elements {
+		if elem.Type != TYPE_STRING {
+			return "", 0, fmt.Errorf("strings.join expects a list of strings, got %s at position %d at line %d", elem.Type, idx, line)
+		}
+		parts[idx] = elem.Value.(string)
+	}
+	sep := ""
+	if len(args) == 2 {
+		sep = args[1].Value.(string)
+	}
+	return TYPE_STRING, strings.Join(parts, sep), nil
+}
+
+// find(s, sub) is the position of the first sub in s, or -1.
+func stringsFind(i *Interpreter, args []Value, line int) (string, any, error) {
+	s := args[0].Value.(string)
+	idx := strings.Index(s, args[1].Value.(string))
+	if idx < 0 {
+		return TYPE_NUMBER, float64(-1), nil
+	}
+	return TYPE_NUMBER, float64(utf8.RuneCountInString(s[:idx])), nil
+}
+
+// replace(s, old, new, count) replaces the first count occurrences of old,
+// or all of them when count is omitted.
+func stringsReplace(i *Interpreter, args []Value, line int) (string, any, error) {
+	count := -1
+	if len(args) == 4 {
+		var err error
+		if count, err = intArg("strings.replace", "count", args[3], line); err != nil {
+			return "", 0, err
+		}
+	}
+	return TYPE_STRING, strings.Replace(args[0].Value.(string), args[1].Value.(string), args[2].Value.(string), count), nil
+}
+
+func stringsStartsWith(i *Interpreter, args []Value, line int) (string, any, error) {
+	return TYPE_BOOL, strings.HasPrefix(args[0].Value.(string), args[1].Value.(string)), nil
+}
+
+func stringsEndsWith(i *Interpreter, args []Value, line int) (string, any, error) {
+	return TYPE_BOOL, strings.HasSuffix(args[0].Value.(string), args[1].Value.(string)), nil
+}
+
+func stringsRepeat(i *Interpreter, args []Value, line int) (string, any, error) {
+	count, err := intArg("strings.repeat", "count", args[1], line)
+	if err != nil {
+		return "", 0, err
+	}
+	if count < 0 {
+		return "", 0, fmt.Errorf("strings.repeat count cannot be negative, got %d at line %d", count, line)
+	}
+	s := args[0].Value.(string)
+	if len(s) > 0 && count > maxStringLen/len(s) {
+		return "", 0, fmt.Errorf("strings.repeat result would be longer than %d bytes at line %d", maxStringLen, line)
+	}
+	return TYPE_STRING, strings.Repeat(s, count), nil
+}
+
+// padding returns the fill that brings s up to width characters. The fill
+// character defaults to a space.
+func padding(fn string, args []Value, line int) (string, error) {
+	width, err := intArg(fn, "width", args[1], line)
+	if err != nil {
+		return "", err
+	}
+	fill := " "
+	if len(args) == 3 {
+		fill = args[2].Value.(string)
+		if utf8.RuneCountInString(fill) != 1 {
+			return "", fmt.Errorf("%s expects a single fill character, got %q at line %d", fn, fill, line)
+		}
+	}
+	if width > maxStringLen/len(fill) {
+		return "", fmt.Errorf("%s width cannot exceed %d, got %d at line %d", fn, maxStringLen/len(fill), width, line)
+	}
+	return strings.Repeat(fill, max(width-utf8.RuneCountInString(args[0].Value.(string)), 0)), nil
+}
+
+func stringsPadLeft(i *Interpreter, args []Value, line int) (string, any, error) {
+	pad, err := padding("strings.pad_left", args, line)
+	if err != nil {
+		return "", 0, err
+	}
+	return TYPE_STRING, pad + args[0].Value.(string), nil
+}
+
+func stringsPadRight(i *Interpreter, args []Value, line int) (string, any, error) {
+	pad, err := padding("strings.pad_right", args, line)
+	if err != nil {
+		return "", 0, err
+	}
+	return TYPE_STRING, args[0].Value.(string) + pad, nil
+}
+
+func stringsLen(i *Interpreter, args []Value, line int) (string, any, error) {
+	return TYPE_NUMBER, float64(utf8.RuneCountInString(args[0].Value.(string))), nil
+}
+
+// sub(s, start, stop) is s[start:stop], the characters from start up to
+// but not including stop. Omitting stop takes the rest of the string.
+func stringsSub(i *Interpreter, args []Value, line int) (string, any, error) {
+	bounds := make([]*int, 2)
+	for idx, arg := range args[1:] {
+		pos, err := toInt(arg.Type, arg.Value, line)
+		if err != nil {
+			return "", 0, err
+		}
+		bounds[idx] = &pos
+	}
+	return sliceValue(TYPE_STRING, args[0].Value, bounds[0], bounds[1], nil, line)
+}
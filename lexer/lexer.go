@@ -13,6 +13,10 @@ type Lexer struct {
 	start  int
 	curr   int
 	line   int
+
+	// OnError reports a lexing error. It must not return; the default,
+	// utils.LexingError, exits the process.
+	OnError func(msg string, line int)
 }
 
 func NewLexer(source []byte) *Lexer {
@@ -22,6 +26,8 @@ func NewLexer(source []byte) *Lexer {
 		curr:   0,
 		start:  0,
 		line:   1,
+
+		OnError: utils.LexingError,
 	}
 }
 
@@ -52,9 +58,21 @@ func (l *Lexer) Tokenize() []token.Token {
 		} else if ch == ',' {
 			l.add_token(token.TOK_COMMA)
 		} else if ch == '.' {
-			l.add_token(token.TOK_DOT)
+			if l.match('.') {
+				if l.match('<') {
+					l.add_token(token.TOK_DOTDOTLT)
+				} else {
+					l.add_token(token.TOK_DOTDOT)
+				}
+			} else {
+				l.add_token(token.TOK_DOT)
+			}
 		} else if ch == '+' {
-			l.add_token(token.TOK_PLUS)
+			if l.match('+') {
+				l.add_token(token.TOK_CONCAT)
+			} else {
+				l.add_token(token.TOK_PLUS)
+			}
 		} else if ch == '-' {
 			if l.match('-') {
 				for l.peek() != '\n' && !(l.curr >= len(l.source)) {
@@ -103,6 +121,12 @@ func (l *Lexer) Tokenize() []token.Token {
 			} else {
 				l.add_token(token.TOK_EQ)
 			}
+		} else if ch == '|' {
+			if l.match('>') {
+				l.add_token(token.TOK_PIPE)
+			} else {
+				l.add_token(token.TOK_BAR)
+			}
 		} else if ch == '~' {
 			if l.match('=') {
 				l.add_token(token.TOK_NE)
@@ -116,7 +140,7 @@ func (l *Lexer) Tokenize() []token.Token {
 		} else if unicode.IsLetter(rune(ch)) || ch == '_' {
 			l.handleIdentifier()
 		} else {
-			utils.LexingError(fmt.Sprintf("Error at %s: Unexpected character.", string(ch)), l.line)
+			l.OnError(fmt.Sprintf("Error at %s: Unexpected character.", string(ch)), l.line)
 		}
 	}
 	return l.tokens
@@ -185,7 +209,7 @@ func (l *Lexer) handleString(start_quote byte) {
 		l.advance()
 	}
 	if l.curr >= len(l.source) {
-		utils.LexingError("Unterminated string.", l.line)
+		l.OnError("Unterminated string.", l.line)
 	}
 	l.advance()
 	l.add_token(token.TOK_STRING)
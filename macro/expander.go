@@ -0,0 +1,227 @@
+package macro
+
+import (
+	"fmt"
+	"inky/ast"
+)
+
+// maxDepth bounds nested expansion so that a macro using itself fails
+// instead of expanding forever.
+const maxDepth = 100
+
+// Expander rewrites macro uses into the macro bodies. It runs between
+// parsing and interpretation, and keeps the macros it has seen so that
+// later chunks (e.g. REPL lines) can use them.
+//
+// Expansion is hygienic: every name the body binds (assignment targets,
+// constants, comprehension variables and match bindings) is renamed to a
+// fresh name for each expansion, so it can neither capture nor overwrite
+// a variable of the caller. Fresh names contain '#', which can never
+// appear in an identifier written in source.
+type Expander struct {
+	Macros  map[string]*ast.MacroDecl
+	counter int
+	depth   int
+}
+
+func NewExpander() *Expander {
+	return &Expander{Macros: map[string]*ast.MacroDecl{}}
+}
+
+// Expand registers the top-level macro declarations in node and returns
+// the tree with every macro use replaced by its expansion.
+func (e *Expander) Expand(node ast.Node) (ast.Node, error) {
+	if program, ok := node.(*ast.Stmts); ok {
+		stmts := []ast.Stmt{}
+		for _, stmt := range program.Stmts {
+			if decl, ok := stmt.(*ast.MacroDecl); ok {
+				e.Macros[decl.Name] = decl
+				continue
+			}
+			stmts = append(stmts, stmt)
+		}
+		node = &ast.Stmts{Stmts: stmts, Line: program.Line}
+	}
+	return e.expand(node)
+}
+
+// macroCall returns the macro a node invokes, if it is a call to one.
+func (e *Expander) macroCall(node ast.Node) (*ast.Call, *ast.MacroDecl) {
+	call, ok := node.(*ast.Call)
+	if !ok {
+		return nil, nil
+	}
+	ident, ok := call.Callee.(*ast.Identifier)
+	if !ok {
+		return nil, nil
+	}
+	return call, e.Macros[ident.Name]
+}
+
+func (e *Expander) expand(node ast.Node) (ast.Node, error) {
+	switch n := node.(type) {
+	case *ast.Stmts:
+		stmts := make([]ast.Stmt, len(n.Stmts))
+		for idx, stmt := range n.Stmts {
+			var err error
+			if call, decl := e.macroCall(stmt); decl != nil {
+				stmts[idx], err = e.expandCall(call, decl)
+			} else {
+				stmts[idx], err = e.expand(stmt)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		return &ast.Stmts{Stmts: stmts, Line: n.Line}, nil
+	case *ast.MacroDecl:
+		return nil, fmt.Errorf("macro %s must be declared at the top level, at line %d", n.Name, n.Line)
+	case *ast.Call:
+		if call, decl := e.macroCall(n); decl != nil {
+			return e.expandExpr(call, decl)
+		}
+		for _, arg := range n.Args {
+			if _, ok := arg.(*ast.Stmts); ok {
+				return nil, fmt.Errorf("a do block can only be passed to a macro, at line %d", n.Line)
+			}
+		}
+	}
+	return mapChildren(node, e.expand)
+}
+
+// expandCall expands a macro used as a statement into a block.
+func (e *Expander) expandCall(call *ast.Call, decl *ast.MacroDecl) (*ast.Stmts, error) {
+	if len(call.Args) != len(decl.Params) {
+		return nil, fmt.Errorf("macro %s expects %d arguments, got %d at line %d", decl.Name, len(decl.Params), len(call.Args), call.Line)
+	}
+	if e.depth >= maxDepth {
+		return nil, fmt.Errorf("macro %s expands too deeply, does it use itself? at line %d", decl.Name, call.Line)
+	}
+	e.depth++
+	defer func() { e.depth-- }()
+
+	args := map[string]ast.Node{}
+	for idx, param := range decl.Params {
+		arg, err := e.expand(call.Args[idx])
+		if err != nil {
+			return nil, err
+		}
+		args[param] = arg
+	}
+
+	e.counter++
+	s := &substitution{args: args, renames: map[string]string{}}
+	for _, name := range binders(decl.Body) {
+		if _, isParam := args[name]; !isParam {
+			s.renames[name] = fmt.Sprintf("%s#%d", name, e.counter)
+		}
+	}
+	body, err := s.apply(decl.Body)
+	if err != nil {
+		return nil, err
+	}
+	expanded, err := e.expand(body)
+	if err != nil {
+		return nil, err
+	}
+	return expanded.(*ast.Stmts), nil
+}
+
+// expandExpr expands a macro used inside an expression. Its body must be
+// a single expression.
+func (e *Expander) expandExpr(call *ast.Call, decl *ast.MacroDecl) (ast.Node, error) {
+	block, err := e.expandCall(call, decl)
+	if err != nil {
+		return nil, err
+	}
+	if len(block.Stmts) != 1 || isStatement(block.Stmts[0]) {
+		return nil, fmt.Errorf("macro %s expands to statements and cannot be used in an expression, at line %d", decl.Name, call.Line)
+	}
+	return block.Stmts[0], nil
+}
+
+func isStatement(node ast.Node) bool {
+	switch node.(type) {
+	case *ast.Stmts, *ast.PrintStmt, *ast.IfStmt, *ast.AssignStmt, *ast.ConstStmt,
+		*ast.RecordDecl, *ast.EnumDecl, *ast.MatchStmt, *ast.MacroDecl:
+		return true
+	default:
+		return false
+	}
+}
+
+// binders lists the names a macro body binds.
+func binders(body ast.Node) []string {
+	names := []string{}
+	var visit func(node ast.Node) (ast.Node, error)
+	visit = func(node ast.Node) (ast.Node, error) {
+		switch n := node.(type) {
+		case *ast.AssignStmt:
+			if ident, ok := n.Left.(*ast.Identifier); ok {
+				names = append(names, ident.Name)
+			}
+		case *ast.ConstStmt:
+			names = append(names, n.Name.Name)
+		case *ast.ListComp:
+			names = append(names, n.Clause.Vars...)
+		case *ast.MapComp:
+			names = append(names, n.Clause.Vars...)
+		case *ast.MatchStmt:
+			for _, c := range n.Cases {
+				names = append(names, c.Bindings...)
+			}
+		}
+		return mapChildren(node, visit)
+	}
+	visit(body)
+	return names
+}
+
+// substitution replaces a macro's parameters by the use site's arguments
+// and renames the names the body binds.
+type substitution struct {
+	args    map[string]ast.Node
+	renames map[string]string
+}
+
+func (s *substitution) rename(names []string) []string {
+	renamed := make([]string, len(names))
+	for idx, name := range names {
+		renamed[idx] = name
+		if fresh, ok := s.renames[name]; ok {
+			renamed[idx] = fresh
+		}
+	}
+	return renamed
+}
+
+func (s *substitution) apply(node ast.Node) (ast.Node, error) {
+	if ident, ok := node.(*ast.Identifier); ok {
+		if arg, ok := s.args[ident.Name]; ok {
+			return arg, nil
+		}
+		if fresh, ok := s.renames[ident.Name]; ok {
+			return &ast.Identifier{Name: fresh, Line: ident.Line}, nil
+		}
+		return ident, nil
+	}
+
+	result, err := mapChildren(node, s.apply)
+	if err != nil {
+		return nil, err
+	}
+	// Binder names that are not Identifier nodes
+	switch n := result.(type) {
+	case *ast.ConstStmt:
+		n.Name.Name = s.rename([]string{n.Name.Name})[0]
+	case *ast.ListComp:
+		n.Clause.Vars = s.rename(n.Clause.Vars)
+	case *ast.MapComp:
+		n.Clause.Vars = s.rename(n.Clause.Vars)
+	case *ast.MatchStmt:
+		for idx := range n.Cases {
+			n.Cases[idx].Bindings = s.rename(n.Cases[idx].Bindings)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,95 @@
+package macro
+
+import "inky/ast"
+
+// mapChildren returns a shallow copy of node whose child nodes have been
+// replaced by f(child). Leaves are returned unchanged.
+func mapChildren(node ast.Node, f func(ast.Node) (ast.Node, error)) (ast.Node, error) {
+	var err error
+	expr := func(child ast.Expr) ast.Expr {
+		if child == nil || err != nil {
+			return child
+		}
+		var result ast.Node
+		result, err = f(child)
+		return result
+	}
+	exprs := func(children []ast.Expr) []ast.Expr {
+		result := make([]ast.Expr, len(children))
+		for idx, child := range children {
+			result[idx] = expr(child)
+		}
+		return result
+	}
+	block := func(child *ast.Stmts) *ast.Stmts {
+		if child == nil || err != nil {
+			return child
+		}
+		var result ast.Node
+		result, err = f(child)
+		if err != nil {
+			return nil
+		}
+		return result.(*ast.Stmts)
+	}
+	clause := func(c ast.CompClause) ast.CompClause {
+		return ast.CompClause{Vars: append([]string{}, c.Vars...), Iterable: expr(c.Iterable), Cond: expr(c.Cond)}
+	}
+
+	var result ast.Node
+	switch n := node.(type) {
+	case *ast.BinOp:
+		result = &ast.BinOp{Op: n.Op, Left: expr(n.Left), Right: expr(n.Right), Line: n.Line}
+	case *ast.UnOp:
+		result = &ast.UnOp{Op: n.Op, Operand: expr(n.Operand), Line: n.Line}
+	case *ast.LogicalOp:
+		result = &ast.LogicalOp{Op: n.Op, Left: expr(n.Left), Right: expr(n.Right), Line: n.Line}
+	case *ast.Grouping:
+		result = &ast.Grouping{Value: expr(n.Value), Line: n.Line}
+	case *ast.Call:
+		result = &ast.Call{Callee: expr(n.Callee), Args: exprs(n.Args), Line: n.Line}
+	case *ast.FieldAccess:
+		result = &ast.FieldAccess{Object: expr(n.Object), Field: n.Field, Line: n.Line}
+	case *ast.Index:
+		result = &ast.Index{Object: expr(n.Object), Index: expr(n.Index), Line: n.Line}
+	case *ast.Slice:
+		result = &ast.Slice{Object: expr(n.Object), Start: expr(n.Start), Stop: expr(n.Stop), Step: expr(n.Step), Line: n.Line}
+	case *ast.Range:
+		result = &ast.Range{Start: expr(n.Start), End: expr(n.End), Inclusive: n.Inclusive, Line: n.Line}
+	case *ast.ListLiteral:
+		result = &ast.ListLiteral{Elements: exprs(n.Elements), Line: n.Line}
+	case *ast.MapLiteral:
+		result = &ast.MapLiteral{Keys: exprs(n.Keys), Values: exprs(n.Values), Line: n.Line}
+	case *ast.ListComp:
+		result = &ast.ListComp{Element: expr(n.Element), Clause: clause(n.Clause), Line: n.Line}
+	case *ast.MapComp:
+		result = &ast.MapComp{Key: expr(n.Key), Value: expr(n.Value), Clause: clause(n.Clause), Line: n.Line}
+	case *ast.Stmts:
+		stmts := make([]ast.Stmt, len(n.Stmts))
+		for idx, stmt := range n.Stmts {
+			stmts[idx] = expr(stmt)
+		}
+		result = &ast.Stmts{Stmts: stmts, Line: n.Line}
+	case *ast.PrintStmt:
+		result = &ast.PrintStmt{Value: expr(n.Value), End: n.End, Line: n.Line}
+	case *ast.IfStmt:
+		result = &ast.IfStmt{Condition: expr(n.Condition), ThenStmts: block(n.ThenStmts), ElseStmts: block(n.ElseStmts), Line: n.Line}
+	case *ast.AssignStmt:
+		result = &ast.AssignStmt{Left: expr(n.Left), Right: expr(n.Right), Type: n.Type, Line: n.Line}
+	case *ast.ConstStmt:
+		result = &ast.ConstStmt{Name: n.Name, Right: expr(n.Right), Type: n.Type, Line: n.Line}
+	case *ast.MatchStmt:
+		cases := make([]ast.MatchCase, len(n.Cases))
+		for idx, c := range n.Cases {
+			cases[idx] = ast.MatchCase{Variant: c.Variant, Bindings: append([]string{}, c.Bindings...), Stmts: block(c.Stmts), Line: c.Line}
+		}
+		result = &ast.MatchStmt{Subject: expr(n.Subject), Cases: cases, ElseStmts: block(n.ElseStmts), Line: n.Line}
+	default:
+		// Literals, identifiers and declarations have no child nodes to rewrite
+		return node, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"inky/checker"
 	"inky/interpreter"
 	"inky/lexer"
+	"inky/macro"
 	"inky/parser"
 	"inky/repl"
 	"inky/utils"
@@ -16,6 +19,37 @@ func die(msg string) {
 	os.Exit(1)
 }
 
+func readSource(filename string) []byte {
+	file, err := os.Open(filename)
+	if err != nil {
+		die("Failed to open file: " + err.Error())
+	}
+	defer file.Close()
+
+	source, err := io.ReadAll(file)
+	if err != nil {
+		die("Failed to read file: " + err.Error())
+	}
+	return source
+}
+
+// check runs the static type checker over source and exits non-zero on errors.
+func check(source []byte) {
+	tokens := lexer.NewLexer(source).Tokenize()
+	ast, err := macro.NewExpander().Expand(parser.NewParser(tokens).Parse())
+	if err != nil {
+		die("Macro Error: " + err.Error())
+	}
+	errors := checker.NewChecker().Check(ast)
+	for _, err := range errors {
+		utils.ColorPrint(utils.RED, err.Error()+"\n")
+	}
+	if len(errors) > 0 {
+		os.Exit(1)
+	}
+	utils.ColorPrint(utils.GREEN, "No type errors found.\n")
+}
+
 func main() {
 	// No arguments, launch REPL
 	if len(os.Args) == 1 {
@@ -24,25 +58,23 @@ func main() {
 		return
 	}
 
-	// Check for correct arguments for file mode
-	if len(os.Args) != 3 || os.Args[1] != "--" {
+	// Check for correct arguments for file mode. Arguments after the
+	// filename are passed to the script as os.args.
+	runMode := len(os.Args) >= 3 && os.Args[1] == "--"
+	checkMode := len(os.Args) == 3 && os.Args[1] == "check"
+	if !runMode && !checkMode {
 		fmt.Println("Usage:")
-		fmt.Println("  inky                # Start REPL mode")
-		fmt.Println("  inky -- <filename>  # Execute file")
+		fmt.Println("  inky                             # Start REPL mode")
+		fmt.Println("  inky -- <filename> [args...]     # Execute file")
+		fmt.Println("  inky check <filename>            # Type check file without running it")
 		os.Exit(1)
 	}
 
-	filename := os.Args[2]
+	source := readSource(os.Args[2])
 
-	file, err := os.Open(filename)
-	if err != nil {
-		die("Failed to open file: " + err.Error())
-	}
-	defer file.Close()
-
-	source, err := io.ReadAll(file)
-	if err != nil {
-		die("Failed to read file: " + err.Error())
+	if checkMode {
+		check(source)
+		return
 	}
 
 	utils.ColorPrint(utils.GREEN, "\n---------------------------\n")
@@ -68,16 +100,29 @@ func main() {
 	fmt.Printf("Original AST: \n%v\n\n", ast)
 	fmt.Printf("Pretty AST: \n%s\n", utils.PrettyPrint(ast))
 
+	inky := interpreter.NewInterpreter()
+	inky.SetArgs(os.Args[3:])
+	expander := inky.Expander // shared so that eval sees the script's macros
+	ast, err := expander.Expand(ast)
+	if err != nil {
+		die("Macro Error: " + err.Error())
+	}
+	if len(expander.Macros) > 0 {
+		utils.ColorPrint(utils.GREEN, "\n---------------------------\n")
+		utils.ColorPrint(utils.GREEN, "Expanded AST:")
+		utils.ColorPrint(utils.GREEN, "\n---------------------------\n")
+		fmt.Printf("Pretty AST: \n%s\n", utils.PrettyPrint(ast))
+	}
+
 	utils.ColorPrint(utils.GREEN, "\n---------------------------\n")
 	utils.ColorPrint(utils.GREEN, "Interpreter:")
 	utils.ColorPrint(utils.GREEN, "\n---------------------------\n")
-	interpreter := interpreter.NewInterpreter()
-	interpreter.Interpret(ast)
-	// typ, result, err := interpreter.Interpret(ast)
-	// if err != nil {
-	// 	die("Interpreter Error: " + err.Error())
-	// }
-	// if typ != "" {
-	// 	fmt.Printf("%v: %v\n\n", typ, result)
-	// }
+	_, _, err = inky.Interpret(ast)
+	inky.Close()
+	var exit *interpreter.ExitError
+	if errors.As(err, &exit) {
+		os.Exit(exit.Code)
+	} else if err != nil {
+		die("Interpreter Error: " + err.Error())
+	}
 }
@@ -6,17 +6,26 @@ import (
 	"inky/token"
 	"inky/utils"
 	"strconv"
+	"strings"
 )
 
 type Parser struct {
-	tokens []token.Token
-	curr   int
+	tokens   []token.Token
+	curr     int
+	consts   []map[string]int         // constant name -> line of its declaration, per enclosing block
+	variants map[string]*ast.EnumDecl // variant name -> enum declaring it
+
+	// OnError reports a syntax error. It must not return, as parsing cannot
+	// continue; the default, utils.ParseError, exits the process.
+	OnError func(msg string, line int)
 }
 
 func NewParser(tokens []token.Token) *Parser {
 	return &Parser{
-		tokens: tokens,
-		curr:   0,
+		tokens:   tokens,
+		curr:     0,
+		variants: map[string]*ast.EnumDecl{},
+		OnError:  utils.ParseError,
 	}
 }
 
@@ -33,16 +42,24 @@ func (p *Parser) program() ast.Stmt {
 
 // stmts ::= stmt+
 func (p *Parser) stmts() *ast.Stmts {
+	p.consts = append(p.consts, map[string]int{})
+	defer func() { p.consts = p.consts[:len(p.consts)-1] }()
+
 	stmts := []ast.Stmt{}
-	for p.curr < len(p.tokens) && p.peek().Type != token.TOK_ELSE && p.peek().Type != token.TOK_END {
+	for p.curr < len(p.tokens) && p.peek().Type != token.TOK_ELSE && p.peek().Type != token.TOK_END && p.peek().Type != token.TOK_CASE {
 		stmt := p.stmt()
 		stmts = append(stmts, stmt)
 	}
-	return &ast.Stmts{Stmts: stmts, Line: p.previousToken().Line}
+	line := 1 // an empty program, such as one that is only comments
+	if p.curr > 0 {
+		line = p.previousToken().Line
+	}
+	return &ast.Stmts{Stmts: stmts, Line: line}
 }
 
 // stmt ::= expr_stmt | print_stmt | assign | local_assign | println_stmt |
-// if_stmt | while_stmt | for_stmt | func_decl | func_call | ret_stmt
+// if_stmt | while_stmt | for_stmt | func_decl | func_call | ret_stmt | const_stmt |
+// record_decl | enum_decl | match_stmt | macro_decl
 func (p *Parser) stmt() ast.Stmt {
 	// TODO: predictive parsing, where the next token predicts what is the next statement
 	// TODO: parse print, if, while, for, assignment, function call, etc.
@@ -52,16 +69,221 @@ func (p *Parser) stmt() ast.Stmt {
 		return p.print_stmt("\n")
 	} else if p.peek().Type == token.TOK_IF {
 		return p.if_stmt()
+	} else if p.peek().Type == token.TOK_CONST {
+		return p.const_stmt()
+	} else if p.peek().Type == token.TOK_RECORD {
+		return p.record_decl()
+	} else if p.peek().Type == token.TOK_ENUM {
+		return p.enum_decl()
+	} else if p.peek().Type == token.TOK_MATCH {
+		return p.match_stmt()
+	} else if p.peek().Type == token.TOK_MACRO {
+		return p.macro_decl()
 	} else {
 		left := p.expr()
-		if p.match(token.TOK_ASSIGN) {
+		typ := p.type_annotation()
+		if typ != "" || p.isNext(token.TOK_ASSIGN) {
+			p.expect(token.TOK_ASSIGN)
+			if ident, ok := left.(*ast.Identifier); ok {
+				p.checkNotConst(ident)
+			}
 			right := p.expr()
-			return &ast.AssignStmt{Left: left, Right: right, Line: p.previousToken().Line}
+			return &ast.AssignStmt{Left: left, Right: right, Type: typ, Line: p.previousToken().Line}
+		} else if call, ok := left.(*ast.Call); ok && p.match(token.TOK_DO) {
+			// call_stmt ::= call 'do' stmts 'end', passing the block as the last argument
+			block := p.stmts()
+			p.expect(token.TOK_END)
+			call.Args = append(call.Args, block)
+			return call
 		} else {
 			// TODO: handle function call in expression
+			return left
+		}
+	}
+}
+
+// const_stmt ::= 'const' identifier type_annotation? ':=' expr
+func (p *Parser) const_stmt() ast.Stmt {
+	p.expect(token.TOK_CONST)
+	name := p.expect(token.TOK_IDENTIFIER)
+	ident := ast.Identifier{Name: name.Lexeme, Line: name.Line}
+	p.checkNotConst(&ident)
+	typ := p.type_annotation()
+	p.expect(token.TOK_ASSIGN)
+	right := p.expr()
+	p.consts[len(p.consts)-1][name.Lexeme] = name.Line
+	return &ast.ConstStmt{Name: ident, Right: right, Type: typ, Line: name.Line}
+}
+
+// record_decl ::= 'record' identifier '(' identifier ( ',' identifier )* ')'
+func (p *Parser) record_decl() ast.Stmt {
+	p.expect(token.TOK_RECORD)
+	name := p.expect(token.TOK_IDENTIFIER)
+	p.expect(token.TOK_LPAREN)
+	fields := []string{}
+	seen := map[string]bool{}
+	for {
+		field := p.expect(token.TOK_IDENTIFIER)
+		if seen[field.Lexeme] {
+			p.OnError(fmt.Sprintf("Duplicate field %q in record %s.", field.Lexeme, name.Lexeme), field.Line)
+		}
+		seen[field.Lexeme] = true
+		fields = append(fields, field.Lexeme)
+		if !p.match(token.TOK_COMMA) {
+			break
+		}
+	}
+	p.expect(token.TOK_RPAREN)
+	return &ast.RecordDecl{Name: name.Lexeme, Fields: fields, Line: name.Line}
+}
+
+// enum_decl ::= 'enum' identifier '=' variant ( '|' variant )*
+// variant   ::= identifier ( '(' identifier ( ',' identifier )* ')' )?
+func (p *Parser) enum_decl() ast.Stmt {
+	p.expect(token.TOK_ENUM)
+	name := p.expect(token.TOK_IDENTIFIER)
+	p.expect(token.TOK_EQ)
+	decl := &ast.EnumDecl{Name: name.Lexeme, Line: name.Line}
+	seen := map[string]bool{}
+	for {
+		variant := p.expect(token.TOK_IDENTIFIER)
+		if seen[variant.Lexeme] {
+			p.OnError(fmt.Sprintf("Duplicate variant %q in enum %s.", variant.Lexeme, name.Lexeme), variant.Line)
+		}
+		seen[variant.Lexeme] = true
+		fields := []string{}
+		if p.match(token.TOK_LPAREN) {
+			fields = p.identifiers()
+			p.expect(token.TOK_RPAREN)
+		}
+		decl.Variants = append(decl.Variants, ast.EnumVariant{Name: variant.Lexeme, Fields: fields})
+		if !p.match(token.TOK_BAR) {
+			break
+		}
+	}
+	for _, variant := range decl.Variants {
+		p.variants[variant.Name] = decl
+	}
+	return decl
+}
+
+// match_stmt ::= 'match' expr ( 'case' pattern 'then' stmts )+ ( 'else' stmts )? 'end'
+// pattern    ::= identifier ( '(' identifier ( ',' identifier )* ')' )?
+func (p *Parser) match_stmt() ast.Stmt {
+	line := p.expect(token.TOK_MATCH).Line
+	match := &ast.MatchStmt{Subject: p.expr(), Line: line}
+	for p.isNext(token.TOK_CASE) {
+		p.advance()
+		variant := p.expect(token.TOK_IDENTIFIER)
+		bindings := []string{}
+		if p.match(token.TOK_LPAREN) {
+			bindings = p.identifiers()
+			p.expect(token.TOK_RPAREN)
+		}
+		p.expect(token.TOK_THEN)
+		stmts := p.stmts()
+		match.Cases = append(match.Cases, ast.MatchCase{Variant: variant.Lexeme, Bindings: bindings, Stmts: stmts, Line: variant.Line})
+	}
+	if len(match.Cases) == 0 {
+		p.OnError("Expected at least one 'case' in match.", line)
+	}
+	if p.match(token.TOK_ELSE) {
+		match.ElseStmts = p.stmts()
+	}
+	p.expect(token.TOK_END)
+	p.checkExhaustive(match)
+	return match
+}
+
+// checkExhaustive verifies a match against the enum it dispatches on, when
+// that enum was declared in this program: every case must name one of its
+// variants with the right number of bindings, and without an 'else' every
+// variant must be covered. Matches on enums declared elsewhere are checked
+// at run time instead.
+func (p *Parser) checkExhaustive(match *ast.MatchStmt) {
+	enum, ok := p.variants[match.Cases[0].Variant]
+	if !ok {
+		return
+	}
+	fields := map[string][]string{}
+	for _, variant := range enum.Variants {
+		fields[variant.Name] = variant.Fields
+	}
+	covered := map[string]bool{}
+	for _, c := range match.Cases {
+		variantFields, ok := fields[c.Variant]
+		if !ok {
+			p.OnError(fmt.Sprintf("%s is not a variant of enum %s.", c.Variant, enum.Name), c.Line)
+		}
+		if covered[c.Variant] {
+			p.OnError(fmt.Sprintf("Duplicate case %s.", c.Variant), c.Line)
+		}
+		if len(c.Bindings) != len(variantFields) {
+			p.OnError(fmt.Sprintf("Case %s binds %d values, but the variant has %d.", c.Variant, len(c.Bindings), len(variantFields)), c.Line)
+		}
+		covered[c.Variant] = true
+	}
+	if match.ElseStmts != nil {
+		return
+	}
+	missing := []string{}
+	for _, variant := range enum.Variants {
+		if !covered[variant.Name] {
+			missing = append(missing, variant.Name)
+		}
+	}
+	if len(missing) > 0 {
+		p.OnError(fmt.Sprintf("Match on %s is not exhaustive, missing %s.", enum.Name, strings.Join(missing, ", ")), match.Line)
+	}
+}
+
+// macro_decl ::= 'macro' identifier '(' identifiers? ')' stmts 'end'
+func (p *Parser) macro_decl() ast.Stmt {
+	p.expect(token.TOK_MACRO)
+	name := p.expect(token.TOK_IDENTIFIER)
+	p.expect(token.TOK_LPAREN)
+	params := []string{}
+	if !p.isNext(token.TOK_RPAREN) {
+		params = p.identifiers()
+	}
+	p.expect(token.TOK_RPAREN)
+	// Hygiene renames the names a body binds, so outer constants don't apply
+	outer := p.consts
+	p.consts = nil
+	body := p.stmts()
+	p.consts = outer
+	p.expect(token.TOK_END)
+	return &ast.MacroDecl{Name: name.Lexeme, Params: params, Body: body, Line: name.Line}
+}
+
+// identifiers ::= identifier ( ',' identifier )*
+func (p *Parser) identifiers() []string {
+	names := []string{p.expect(token.TOK_IDENTIFIER).Lexeme}
+	for p.match(token.TOK_COMMA) {
+		names = append(names, p.expect(token.TOK_IDENTIFIER).Lexeme)
+	}
+	return names
+}
+
+// type_annotation ::= ( ':' identifier )?
+func (p *Parser) type_annotation() string {
+	if p.match(token.TOK_COLON) {
+		return p.expect(token.TOK_IDENTIFIER).Lexeme
+	}
+	return ""
+}
+
+// checkNotConst rejects a binding to a name declared const earlier in this
+// block or an enclosing one, where the declaration must have run first.
+// Constants from a block that has ended may not have been declared, e.g. in
+// an untaken branch, and those from an earlier parse (e.g. a previous REPL
+// line) are unknown, so both are left to the check at run time.
+func (p *Parser) checkNotConst(ident *ast.Identifier) {
+	for _, consts := range p.consts {
+		if line, ok := consts[ident.Name]; ok {
+			p.OnError(fmt.Sprintf("Cannot reassign constant %q declared at line %d.", ident.Name, line), ident.Line)
 		}
 	}
-	return nil
 }
 
 // if_stmt  ::= 'if' expr 'then' stmts
@@ -89,9 +311,26 @@ func (p *Parser) print_stmt(end string) ast.Stmt {
 	return nil
 }
 
-// expr ::= or_logical
+// expr ::= pipeline
 func (p *Parser) expr() ast.Expr {
-	return p.or_logical()
+	return p.pipeline()
+}
+
+// pipeline ::= or_logical ( '|>' or_logical )*
+// 'x |> f(a)' is desugared into the call 'f(x, a)', and 'x |> f' into 'f(x)'.
+func (p *Parser) pipeline() ast.Expr {
+	expr := p.or_logical()
+	for p.match(token.TOK_PIPE) {
+		op := p.previousToken()
+		right := p.or_logical()
+		if call, ok := right.(*ast.Call); ok {
+			call.Args = append([]ast.Expr{expr}, call.Args...)
+			expr = call
+		} else {
+			expr = &ast.Call{Callee: right, Args: []ast.Expr{expr}, Line: op.Line}
+		}
+	}
+	return expr
 }
 
 // or_logical ::= and_logical ( 'or' and_logical )*
@@ -127,21 +366,32 @@ func (p *Parser) equality() ast.Expr {
 	return expr
 }
 
-// comparison ::= addition ( ( '>' | '>=' | '<' | '<=' ) addition )*
+// comparison ::= range ( ( '>' | '>=' | '<' | '<=' | 'in' ) range )*
 func (p *Parser) comparison() ast.Expr {
-	expr := p.addition()
-	for p.match(token.TOK_GT) || p.match(token.TOK_GE) || p.match(token.TOK_LT) || p.match(token.TOK_LE) {
+	expr := p.range_expr()
+	for p.match(token.TOK_GT) || p.match(token.TOK_GE) || p.match(token.TOK_LT) || p.match(token.TOK_LE) || p.match(token.TOK_IN) {
 		op := p.previousToken()
-		right := p.addition()
+		right := p.range_expr()
 		expr = &ast.BinOp{Op: op, Left: expr, Right: right, Line: op.Line}
 	}
 	return expr
 }
 
-// addition ::= multiplication ( ( '+' | '-' ) multiplication )*
+// range ::= addition ( ( '..' | '..<' ) addition )?
+func (p *Parser) range_expr() ast.Expr {
+	expr := p.addition()
+	if p.match(token.TOK_DOTDOT) || p.match(token.TOK_DOTDOTLT) {
+		op := p.previousToken()
+		end := p.addition()
+		return &ast.Range{Start: expr, End: end, Inclusive: op.Type == token.TOK_DOTDOT, Line: op.Line}
+	}
+	return expr
+}
+
+// addition ::= multiplication ( ( '+' | '-' | '++' ) multiplication )*
 func (p *Parser) addition() ast.Expr {
 	expr := p.multiplication()
-	for p.match(token.TOK_PLUS) || p.match(token.TOK_MINUS) {
+	for p.match(token.TOK_PLUS) || p.match(token.TOK_MINUS) || p.match(token.TOK_CONCAT) {
 		op := p.previousToken()
 		right := p.multiplication()
 		expr = &ast.BinOp{Op: op, Left: expr, Right: right, Line: op.Line}
@@ -181,9 +431,9 @@ func (p *Parser) unary() ast.Expr {
 	return p.exponent()
 }
 
-// exponent ::= primary ( '^' exponent )*
+// exponent ::= postfix ( '^' exponent )*
 func (p *Parser) exponent() ast.Expr {
-	expr := p.primary()
+	expr := p.postfix()
 	if p.match(token.TOK_CARET) {
 		op := p.previousToken()
 		right := p.exponent() // Recursively parse the right side for right-associativity
@@ -192,7 +442,74 @@ func (p *Parser) exponent() ast.Expr {
 	return expr
 }
 
-// ‹primary> ::= <integer> | ‹float> | '(' ‹expr> ')' | <bool> | <string> | <identifier>
+// postfix ::= primary ( '(' args ')' | '[' subscript ']' | '.' identifier )*
+func (p *Parser) postfix() ast.Expr {
+	expr := p.primary()
+	for {
+		// A '(' or '[' on a new line starts the next statement, not a call or subscript
+		sameLine := p.curr < len(p.tokens) && p.peek().Line == p.previousToken().Line
+		if sameLine && p.isNext(token.TOK_LPAREN) {
+			line := p.advance().Line
+			args := p.args()
+			p.expect(token.TOK_RPAREN)
+			expr = &ast.Call{Callee: expr, Args: args, Line: line}
+		} else if sameLine && p.isNext(token.TOK_LSQUAR) {
+			line := p.advance().Line
+			expr = p.subscript(expr, line)
+			p.expect(token.TOK_RSQUAR)
+		} else if p.match(token.TOK_DOT) {
+			field := p.fieldName()
+			expr = &ast.FieldAccess{Object: expr, Field: field.Lexeme, Line: field.Line}
+		} else {
+			return expr
+		}
+	}
+}
+
+// fieldName reads the name after a '.'. Keywords are allowed there, since
+// a field can't be confused with the statement a keyword starts, e.g. re.match.
+func (p *Parser) fieldName() token.Token {
+	if p.curr < len(p.tokens) && token.Keywords[p.peek().Lexeme] == p.peek().Type {
+		return p.advance()
+	}
+	return p.expect(token.TOK_IDENTIFIER)
+}
+
+// subscript ::= expr | expr? ':' expr? ( ':' expr? )?
+func (p *Parser) subscript(object ast.Expr, line int) ast.Expr {
+	var start ast.Expr
+	if !p.isNext(token.TOK_COLON) {
+		start = p.expr()
+		if !p.isNext(token.TOK_COLON) {
+			return &ast.Index{Object: object, Index: start, Line: line}
+		}
+	}
+	slice := &ast.Slice{Object: object, Start: start, Line: line}
+	p.expect(token.TOK_COLON)
+	if !p.isNext(token.TOK_COLON) && !p.isNext(token.TOK_RSQUAR) {
+		slice.Stop = p.expr()
+	}
+	if p.match(token.TOK_COLON) && !p.isNext(token.TOK_RSQUAR) {
+		slice.Step = p.expr()
+	}
+	return slice
+}
+
+// args ::= ( expr ( ',' expr )* )?
+func (p *Parser) args() []ast.Expr {
+	args := []ast.Expr{}
+	if p.isNext(token.TOK_RPAREN) {
+		return args
+	}
+	args = append(args, p.expr())
+	for p.match(token.TOK_COMMA) {
+		args = append(args, p.expr())
+	}
+	return args
+}
+
+// ‹primary> ::= <integer> | ‹float> | '(' ‹expr> ')' | <bool> | <string> | 'null' |
+// <list> | <map> | <identifier>
 func (p *Parser) primary() ast.Expr {
 	if p.match(token.TOK_INTEGER) {
 		val, _ := strconv.Atoi(p.previousToken().Lexeme)
@@ -204,24 +521,86 @@ func (p *Parser) primary() ast.Expr {
 		return &ast.Bool{Value: true, Line: p.previousToken().Line}
 	} else if p.match(token.TOK_FALSE) {
 		return &ast.Bool{Value: false, Line: p.previousToken().Line}
+	} else if p.match(token.TOK_NULL) {
+		return &ast.Null{Line: p.previousToken().Line}
 	} else if p.match(token.TOK_STRING) {
 		return &ast.String{Value: p.previousToken().Lexeme[1 : len(p.previousToken().Lexeme)-1], Line: p.previousToken().Line} // Remove the quotes from the string
+	} else if p.isNext(token.TOK_LSQUAR) {
+		return p.list()
+	} else if p.isNext(token.TOK_LCURLY) {
+		return p.map_expr()
 	} else if p.match(token.TOK_LPAREN) {
 		expr := p.expr()
 		if !p.match(token.TOK_RPAREN) {
-			utils.ParseError("Error: ')' expected.", p.previousToken().Line)
+			p.OnError("Error: ')' expected.", p.previousToken().Line)
 		}
 		return &ast.Grouping{Value: expr, Line: p.previousToken().Line}
 	} else {
 		identifier := p.expect(token.TOK_IDENTIFIER)
-		return ast.Identifier{
+		return &ast.Identifier{
 			Name: identifier.Lexeme,
 			Line: p.previousToken().Line,
 		}
-		// TODO: function call inside expessions
 	}
 }
 
+// list ::= '[' ( expr ( ',' expr )* | expr comp_clause )? ']'
+func (p *Parser) list() ast.Expr {
+	line := p.expect(token.TOK_LSQUAR).Line
+	elements := []ast.Expr{}
+	if !p.isNext(token.TOK_RSQUAR) {
+		elements = append(elements, p.expr())
+		if p.isNext(token.TOK_FOR) {
+			clause := p.comp_clause()
+			p.expect(token.TOK_RSQUAR)
+			return &ast.ListComp{Element: elements[0], Clause: clause, Line: line}
+		}
+		for p.match(token.TOK_COMMA) {
+			elements = append(elements, p.expr())
+		}
+	}
+	p.expect(token.TOK_RSQUAR)
+	return &ast.ListLiteral{Elements: elements, Line: line}
+}
+
+// map ::= '{' ( expr ':' expr ( ',' expr ':' expr )* | expr ':' expr comp_clause )? '}'
+func (p *Parser) map_expr() ast.Expr {
+	line := p.expect(token.TOK_LCURLY).Line
+	keys, values := []ast.Expr{}, []ast.Expr{}
+	if !p.isNext(token.TOK_RCURLY) {
+		for {
+			keys = append(keys, p.expr())
+			p.expect(token.TOK_COLON)
+			values = append(values, p.expr())
+			if len(keys) == 1 && p.isNext(token.TOK_FOR) {
+				clause := p.comp_clause()
+				p.expect(token.TOK_RCURLY)
+				return &ast.MapComp{Key: keys[0], Value: values[0], Clause: clause, Line: line}
+			}
+			if !p.match(token.TOK_COMMA) {
+				break
+			}
+		}
+	}
+	p.expect(token.TOK_RCURLY)
+	return &ast.MapLiteral{Keys: keys, Values: values, Line: line}
+}
+
+// comp_clause ::= 'for' identifier ( ',' identifier )? 'in' expr ( 'if' expr )?
+func (p *Parser) comp_clause() ast.CompClause {
+	p.expect(token.TOK_FOR)
+	vars := []string{p.expect(token.TOK_IDENTIFIER).Lexeme}
+	if p.match(token.TOK_COMMA) {
+		vars = append(vars, p.expect(token.TOK_IDENTIFIER).Lexeme)
+	}
+	p.expect(token.TOK_IN)
+	clause := ast.CompClause{Vars: vars, Iterable: p.expr()}
+	if p.match(token.TOK_IF) {
+		clause.Cond = p.expr()
+	}
+	return clause
+}
+
 // Utility methods
 func (p *Parser) match(expectedType token.TokenType) bool {
 	if p.curr >= len(p.tokens) {
@@ -244,11 +623,11 @@ func (p *Parser) peek() token.Token {
 
 func (p *Parser) expect(expectedType token.TokenType) token.Token {
 	if p.curr >= len(p.tokens) {
-		utils.ParseError(fmt.Sprintf("Found %v at the end of parsing", p.previousToken().Lexeme), p.previousToken().Line)
+		p.OnError(fmt.Sprintf("Found %v at the end of parsing", p.previousToken().Lexeme), p.previousToken().Line)
 	} else if p.tokens[p.curr].Type == expectedType {
 		return p.advance()
 	} else {
-		utils.ParseError(fmt.Sprintf("Expected %v, found %v.", expectedType, p.peek().Lexeme), p.peek().Line)
+		p.OnError(fmt.Sprintf("Expected %v, found %v.", expectedType, p.peek().Lexeme), p.peek().Line)
 	}
 	return token.Token{} // unreachable, but required
 }
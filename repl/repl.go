@@ -2,6 +2,7 @@ package repl
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"inky/interpreter"
 	"inky/lexer"
@@ -19,12 +20,15 @@ type REPL struct {
 }
 
 func NewREPL() *REPL {
-	return &REPL{
+	repl := &REPL{
 		interpreter: interpreter.NewInterpreter(),
 		reader:      bufio.NewReader(os.Stdin),
 		prompt:      "inky> ",
 		isRunning:   false,
 	}
+	// Scripts reading stdin through io.read_line share the REPL's reader
+	repl.interpreter.Stdin = repl.reader
+	return repl
 }
 
 func (r *REPL) Run() {
@@ -79,6 +83,7 @@ func (r *REPL) handleSpecialCommands(input string) bool {
 	switch command {
 	case "exit", "quit":
 		r.isRunning = false
+		r.interpreter.Close()
 		fmt.Println("Goodbye!")
 		return true
 	case "help":
@@ -128,11 +133,25 @@ func (r *REPL) evaluate(source []byte) {
 		return
 	}
 
-	typ, result, err := r.interpreter.Interpret(ast)
+	// The interpreter's expander keeps macros declared on earlier lines
+	expanded, err := r.interpreter.Expander.Expand(ast)
 	if err != nil {
+		utils.ColorPrint(utils.RED, fmt.Sprintf("Macro error: %v\n", err))
+		return
+	}
+
+	typ, result, err := r.interpreter.Interpret(expanded)
+	var exit *interpreter.ExitError
+	if errors.As(err, &exit) {
+		r.interpreter.Close()
+		os.Exit(exit.Code)
+	} else if err != nil {
 		utils.ColorPrint(utils.RED, fmt.Sprintf("Interpreter error: %v\n", err))
 		return
 	}
 
-	utils.ColorPrint(utils.WHITE, fmt.Sprintf("%v: %v\n", typ, result))
+	// Statements such as assignments produce no value to echo
+	if typ != "" {
+		utils.ColorPrint(utils.WHITE, fmt.Sprintf("%v: %v\n", typ, result))
+	}
 }
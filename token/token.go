@@ -27,6 +27,7 @@ const (
 	TOK_GT        TokenType = "TOK_GT"        // >
 	TOK_LT        TokenType = "TOK_LT"        // <
 	TOK_EQ        TokenType = "TOK_EQ"        // ==
+	TOK_BAR       TokenType = "TOK_BAR"       // |
 
 	// Two-character tokens
 	TOK_GE     TokenType = "TOK_GE"     // >=
@@ -36,6 +37,12 @@ const (
 	TOK_ASSIGN TokenType = "TOK_ASSIGN" // :=
 	TOK_GTGT   TokenType = "TOK_GTGT"   // >>
 	TOK_LTLT   TokenType = "TOK_LTLT"   // <<
+	TOK_DOTDOT TokenType = "TOK_DOTDOT" // ..
+	TOK_CONCAT TokenType = "TOK_CONCAT" // ++
+	TOK_PIPE   TokenType = "TOK_PIPE"   // |>
+
+	// Three-character tokens
+	TOK_DOTDOTLT TokenType = "TOK_DOTDOTLT" // ..<
 
 	// Literals
 	TOK_IDENTIFIER TokenType = "TOK_IDENTIFIER"
@@ -60,6 +67,13 @@ const (
 	TOK_PRINT   TokenType = "TOK_PRINT"
 	TOK_PRINTLN TokenType = "TOK_PRINTLN"
 	TOK_RET     TokenType = "TOK_RET"
+	TOK_CONST   TokenType = "TOK_CONST"
+	TOK_RECORD  TokenType = "TOK_RECORD"
+	TOK_IN      TokenType = "TOK_IN"
+	TOK_ENUM    TokenType = "TOK_ENUM"
+	TOK_MATCH   TokenType = "TOK_MATCH"
+	TOK_CASE    TokenType = "TOK_CASE"
+	TOK_MACRO   TokenType = "TOK_MACRO"
 )
 
 var Keywords = map[string]TokenType{
@@ -79,6 +93,13 @@ var Keywords = map[string]TokenType{
 	"print":   TOK_PRINT,
 	"println": TOK_PRINTLN,
 	"ret":     TOK_RET,
+	"const":   TOK_CONST,
+	"record":  TOK_RECORD,
+	"in":      TOK_IN,
+	"enum":    TOK_ENUM,
+	"match":   TOK_MATCH,
+	"case":    TOK_CASE,
+	"macro":   TOK_MACRO,
 }
 
 type Token struct {
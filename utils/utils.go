@@ -16,6 +16,15 @@ func (w *wrappedStmts) String() string {
 	return fmt.Sprintf("%s: %s", w.label, w.stmts.String())
 }
 
+type wrappedExpr struct {
+	expr  ast.Expr
+	label string
+}
+
+func (w *wrappedExpr) String() string {
+	return fmt.Sprintf("%s: %s", w.label, w.expr.String())
+}
+
 func PrettyPrint(node ast.Node) string {
 	lines := []string{}
 	buildTreeLines(node, "", "", &lines)
@@ -44,6 +53,8 @@ func buildTreeLines(node ast.Node, prefix string, childrenPrefix string, lines *
 		nodeDesc = fmt.Sprintf("● String: %s", n.Value)
 	case *ast.Bool:
 		nodeDesc = fmt.Sprintf("● Bool: %t", n.Value)
+	case *ast.Null:
+		nodeDesc = "● Null"
 	case *ast.LogicalOp:
 		nodeDesc = fmt.Sprintf("● LogicalOp: %q", n.Op.Lexeme)
 		children = []ast.Node{n.Left, n.Right}
@@ -65,6 +76,92 @@ func buildTreeLines(node ast.Node, prefix string, childrenPrefix string, lines *
 		if n.ElseStmts != nil {
 			children = append(children, &wrappedStmts{n.ElseStmts, "ElseBlock"})
 		}
+	case *ast.Identifier:
+		nodeDesc = fmt.Sprintf("● Identifier: %s", n.Name)
+	case *ast.AssignStmt:
+		nodeDesc = "● AssignStmt"
+		if n.Type != "" {
+			nodeDesc = fmt.Sprintf("● AssignStmt: %s", n.Type)
+		}
+		children = []ast.Node{n.Left, n.Right}
+	case *ast.ConstStmt:
+		nodeDesc = fmt.Sprintf("● ConstStmt: %s", n.Name.Name)
+		if n.Type != "" {
+			nodeDesc = fmt.Sprintf("● ConstStmt: %s: %s", n.Name.Name, n.Type)
+		}
+		children = []ast.Node{n.Right}
+	case *ast.RecordDecl:
+		nodeDesc = fmt.Sprintf("● RecordDecl: %s(%s)", n.Name, strings.Join(n.Fields, ", "))
+	case *ast.Call:
+		nodeDesc = "● Call"
+		children = []ast.Node{n.Callee}
+		for _, arg := range n.Args {
+			children = append(children, arg)
+		}
+	case *ast.FieldAccess:
+		nodeDesc = fmt.Sprintf("● FieldAccess: .%s", n.Field)
+		children = []ast.Node{n.Object}
+	case *ast.Index:
+		nodeDesc = "● Index"
+		children = []ast.Node{n.Object, n.Index}
+	case *ast.Slice:
+		nodeDesc = "● Slice"
+		children = []ast.Node{n.Object}
+		if n.Start != nil {
+			children = append(children, &wrappedExpr{n.Start, "Start"})
+		}
+		if n.Stop != nil {
+			children = append(children, &wrappedExpr{n.Stop, "Stop"})
+		}
+		if n.Step != nil {
+			children = append(children, &wrappedExpr{n.Step, "Step"})
+		}
+	case *ast.Range:
+		nodeDesc = "● Range: .."
+		if !n.Inclusive {
+			nodeDesc = "● Range: ..<"
+		}
+		children = []ast.Node{n.Start, n.End}
+	case *ast.EnumDecl:
+		variants := make([]string, len(n.Variants))
+		for i, variant := range n.Variants {
+			variants[i] = variant.String()
+		}
+		nodeDesc = fmt.Sprintf("● EnumDecl: %s = %s", n.Name, strings.Join(variants, " | "))
+	case *ast.MacroDecl:
+		nodeDesc = fmt.Sprintf("● MacroDecl: %s(%s)", n.Name, strings.Join(n.Params, ", "))
+		children = []ast.Node{n.Body}
+	case *ast.MatchStmt:
+		nodeDesc = "● MatchStmt"
+		children = []ast.Node{n.Subject}
+		for _, c := range n.Cases {
+			pattern := ast.EnumVariant{Name: c.Variant, Fields: c.Bindings}
+			children = append(children, &wrappedStmts{c.Stmts, "Case " + pattern.String()})
+		}
+		if n.ElseStmts != nil {
+			children = append(children, &wrappedStmts{n.ElseStmts, "ElseBlock"})
+		}
+	case *ast.ListLiteral:
+		nodeDesc = "● ListLiteral"
+		for _, elem := range n.Elements {
+			children = append(children, elem)
+		}
+	case *ast.MapLiteral:
+		nodeDesc = "● MapLiteral"
+		for i := range n.Keys {
+			children = append(children, &wrappedExpr{n.Keys[i], "Key"}, &wrappedExpr{n.Values[i], "Value"})
+		}
+	case *ast.ListComp:
+		nodeDesc = fmt.Sprintf("● ListComp: for %s", strings.Join(n.Clause.Vars, ", "))
+		children = []ast.Node{&wrappedExpr{n.Element, "Element"}}
+		children = append(children, compClauseChildren(n.Clause)...)
+	case *ast.MapComp:
+		nodeDesc = fmt.Sprintf("● MapComp: for %s", strings.Join(n.Clause.Vars, ", "))
+		children = []ast.Node{&wrappedExpr{n.Key, "Key"}, &wrappedExpr{n.Value, "Value"}}
+		children = append(children, compClauseChildren(n.Clause)...)
+	case *wrappedExpr:
+		nodeDesc = fmt.Sprintf("● %s", n.label)
+		children = []ast.Node{n.expr}
 	case *wrappedStmts:
 		nodeDesc = fmt.Sprintf("● %s", n.label)
 		children = []ast.Node{}
@@ -94,6 +191,14 @@ func buildTreeLines(node ast.Node, prefix string, childrenPrefix string, lines *
 	}
 }
 
+func compClauseChildren(clause ast.CompClause) []ast.Node {
+	children := []ast.Node{&wrappedExpr{clause.Iterable, "In"}}
+	if clause.Cond != nil {
+		children = append(children, &wrappedExpr{clause.Cond, "If"})
+	}
+	return children
+}
+
 const (
 	WHITE  = "\033[0m"
 	BLUE   = "\033[94m"